@@ -0,0 +1,136 @@
+package lushauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/LUSHDigital/uuid"
+)
+
+// Actor represents the party acting on behalf of the consumer of a set of claims.
+// A structured version of the act claim, as referenced at
+// https://tools.ietf.org/html/rfc8693#section-4.1
+type Actor struct {
+	// Subject identifies the actor, typically the UUID of the acting consumer.
+	Subject string `json:"sub,omitempty"`
+	// Consumer is the consumer acting on behalf of the consumer of the claims.
+	Consumer Consumer `json:"consumer"`
+	// Service is set instead of the consumer when a service is acting on behalf of the consumer of the claims.
+	Service *Service `json:"service,omitempty"`
+	// Actor is the party the actor was itself acting on behalf of, if any.
+	Actor *Actor `json:"act,omitempty"`
+}
+
+// NewClaimsForImpersonation spawns new claims for a consumer with an actor acting on their behalf.
+func NewClaimsForImpersonation(issuer string, actor, consumer Consumer) (Claims, error) {
+	var c Claims
+	now := TimeFunc()
+	id, err := uuid.NewV4()
+	if err != nil {
+		return c, err
+	}
+	return Claims{
+		ID:        id.String(),
+		Issuer:    issuer,
+		ExpiresAt: now.Add(DefaultValidPeriod).Unix(),
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		Consumer:  consumer,
+		Actor: &Actor{
+			Subject:  actor.UUID,
+			Consumer: actor,
+		},
+	}, nil
+}
+
+// NewClaimsForDelegation spawns new claims for a consumer with a service acting on their behalf.
+// Any actor already acting on behalf of the consumer is kept as the actor of the service.
+func NewClaimsForDelegation(issuer string, service Service, consumer Consumer, actor *Actor) (Claims, error) {
+	claims, err := NewClaimsForConsumer(issuer, consumer)
+	if err != nil {
+		return claims, err
+	}
+	claims.ExpiresAt = TimeFunc().Add(DefaultServiceValidPeriod).Unix()
+	claims.Actor = &Actor{
+		Subject: service.ClientID,
+		Service: &service,
+		Actor:   actor,
+	}
+	return claims, nil
+}
+
+// NoImpersonationPolicy denies access when a consumer is acting on behalf of the consumer.
+// Services acting on behalf of the consumer are permitted unless a consumer is acting through them.
+type NoImpersonationPolicy struct{}
+
+// PermitResource permits a consumer or returns an error.
+func (p NoImpersonationPolicy) PermitResource(ctx context.Context, c Consumer, r Resource) error {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	for a := &actor; a != nil; a = a.Actor {
+		if a.Service == nil {
+			return p
+		}
+	}
+	return nil
+}
+
+func (p NoImpersonationPolicy) Error() string {
+	return "can not be performed on behalf of another user"
+}
+
+// errConsumerActor happens when a consumer is acting on behalf of the consumer but consumers are not allowed to.
+var errConsumerActor = errors.New("consumers can not act on behalf of the consumer")
+
+// errServiceActor happens when a service is acting on behalf of the consumer but services are not allowed to.
+var errServiceActor = errors.New("services can not act on behalf of the consumer")
+
+// ActorPolicy defines what actors are allowed to act on behalf of the consumer.
+// Access is permitted when no actor is acting on behalf of the consumer.
+type ActorPolicy struct {
+	// Policy permits the consumers that are allowed to act on behalf of the consumer.
+	Policy Permitter
+	// ServicePolicy permits the services that are allowed to act on behalf of the consumer, such as a ScopePolicy.
+	// Services acting on behalf of the consumer are denied when it is nil.
+	ServicePolicy ResourcePermitter
+}
+
+// PermitResource permits a consumer or returns an error.
+// Every actor in the chain of actors acting on behalf of the consumer must be permitted.
+func (p ActorPolicy) PermitResource(ctx context.Context, c Consumer, r Resource) error {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	for a := &actor; a != nil; a = a.Actor {
+		if err := p.permitActor(ctx, c, r, *a); err != nil {
+			return ActorPolicyError{err}
+		}
+	}
+	return nil
+}
+
+func (p ActorPolicy) permitActor(ctx context.Context, c Consumer, r Resource, actor Actor) error {
+	if actor.Service != nil {
+		if p.ServicePolicy == nil {
+			return errServiceActor
+		}
+		return p.ServicePolicy.PermitResource(ContextWithService(ctx, *actor.Service), c, r)
+	}
+	if p.Policy == nil {
+		return errConsumerActor
+	}
+	return p.Policy.Permit(actor.Consumer)
+}
+
+// ActorPolicyError happens when an actor is not allowed to act on behalf of the consumer.
+type ActorPolicyError struct {
+	Err error
+}
+
+func (e ActorPolicyError) Error() string {
+	return fmt.Sprintf("actor is not permitted: %v", e.Err)
+}
@@ -0,0 +1,233 @@
+package lushauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var Support = lushauth.Consumer{
+	UUID:  "9c1a5b1e-8c3f-4c0b-9a5e-6f1f0d3b2a11",
+	Roles: []string{"support"},
+}
+
+func ExampleActorFromContext() {
+	ctx := context.Background()
+	if actor, ok := lushauth.ActorFromContext(ctx); ok {
+		consumer := lushauth.ConsumerFromContext(ctx)
+		log.Printf("%s is acting on behalf of %s", actor.Subject, consumer.UUID)
+	}
+}
+
+func TestNewClaimsForImpersonation(t *testing.T) {
+	c, err := lushauth.NewClaimsForImpersonation("Test", Support, Guest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Equals(t, Guest.UUID, c.Consumer.UUID)
+	test.Equals(t, Support.UUID, c.Actor.Subject)
+	test.Equals(t, Support, c.Actor.Consumer)
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded lushauth.Claims
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	test.Equals(t, c.Actor, decoded.Actor)
+}
+
+func TestNewClaimsForDelegation(t *testing.T) {
+	support := &lushauth.Actor{Subject: Support.UUID, Consumer: Support}
+	c, err := lushauth.NewClaimsForDelegation("Test", batchJob, Guest, support)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Equals(t, Guest, c.Consumer)
+	test.Equals(t, &lushauth.Actor{Subject: batchJob.ClientID, Service: &batchJob, Actor: support}, c.Actor)
+	test.Equals(t, now.Add(lushauth.DefaultServiceValidPeriod).Unix(), c.ExpiresAt)
+	test.Equals(t, nil, c.Valid())
+}
+
+func TestContextWithClaims_Actor(t *testing.T) {
+	c, err := lushauth.NewClaimsForImpersonation("Test", Support, Guest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := lushauth.ContextWithClaims(context.Background(), c)
+	actor, ok := lushauth.ActorFromContext(ctx)
+	test.Equals(t, true, ok)
+	test.Equals(t, Support.UUID, actor.Consumer.UUID)
+	test.Equals(t, Guest.UUID, lushauth.ConsumerFromContext(ctx).UUID)
+
+	_, ok = lushauth.ActorFromContext(lushauth.ContextWithClaims(context.Background(), validClaims))
+	test.Equals(t, false, ok)
+}
+
+func TestImpersonationPolicies_PermitResource(t *testing.T) {
+	var (
+		SupportActorPolicy = lushauth.ActorPolicy{Policy: lushauth.RolePolicy{"support"}}
+		AdminActorPolicy   = lushauth.ActorPolicy{Policy: lushauth.RolePolicy{"admin"}}
+	)
+	impersonated, err := lushauth.NewClaimsForImpersonation("Test", Support, Guest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impersonatedCtx := lushauth.ContextWithClaims(context.Background(), impersonated)
+	directCtx := lushauth.ContextWithClaims(context.Background(), lushauth.Claims{Consumer: Guest})
+	delegated, err := lushauth.NewClaimsForDelegation("Test", batchJob, Guest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegatedCtx := lushauth.ContextWithClaims(context.Background(), delegated)
+	delegatedImpersonation, err := lushauth.NewClaimsForDelegation("Test", batchJob, Guest, impersonated.Actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegatedImpersonationCtx := lushauth.ContextWithClaims(context.Background(), delegatedImpersonation)
+	cases := []struct {
+		name     string
+		ctx      context.Context
+		policy   lushauth.ResourcePermitter
+		expected error
+	}{
+		{
+			name:   "no impersonation policy without actor",
+			ctx:    directCtx,
+			policy: lushauth.NoImpersonationPolicy{},
+		},
+		{
+			name:     "no impersonation policy with actor",
+			ctx:      impersonatedCtx,
+			policy:   lushauth.NoImpersonationPolicy{},
+			expected: lushauth.NoImpersonationPolicy{},
+		},
+		{
+			name:   "no impersonation policy with service actor",
+			ctx:    delegatedCtx,
+			policy: lushauth.NoImpersonationPolicy{},
+		},
+		{
+			name:     "no impersonation policy with consumer actor behind service actor",
+			ctx:      delegatedImpersonationCtx,
+			policy:   lushauth.NoImpersonationPolicy{},
+			expected: lushauth.NoImpersonationPolicy{},
+		},
+		{
+			name:   "actor policy without actor",
+			ctx:    directCtx,
+			policy: AdminActorPolicy,
+		},
+		{
+			name:   "actor policy with permitted actor",
+			ctx:    impersonatedCtx,
+			policy: SupportActorPolicy,
+		},
+		{
+			name:     "actor policy with actor lacking the role",
+			ctx:      impersonatedCtx,
+			policy:   AdminActorPolicy,
+			expected: lushauth.ActorPolicyError{Err: lushauth.RolePolicy{"admin"}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.policy.PermitResource(c.ctx, lushauth.ConsumerFromContext(c.ctx), nil)
+			test.Equals(t, c.expected, err)
+		})
+	}
+}
+
+func TestActorPolicy_Delegation(t *testing.T) {
+	delegated, err := lushauth.NewClaimsForDelegation("Test", batchJob, Guest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := must(jwt.NewWithClaims(jwt.SigningMethodRS256, &delegated).SignedString(rsaPriv))
+	var parsed lushauth.Claims
+	if _, err := jwt.ParseWithClaims(raw, &parsed, lushauth.RSAKeyFunc(public)); err != nil {
+		t.Fatal(err)
+	}
+	ctx := lushauth.ContextWithClaims(context.Background(), parsed)
+	cases := []struct {
+		name     string
+		policy   lushauth.ActorPolicy
+		expected error
+	}{
+		{
+			name:   "service actor with permitted scope",
+			policy: lushauth.ActorPolicy{ServicePolicy: lushauth.ScopePolicy{"stock.update"}},
+		},
+		{
+			name:   "service actor permitted by client id",
+			policy: lushauth.ActorPolicy{ServicePolicy: lushauth.ServicePolicy{batchJob.ClientID}},
+		},
+		{
+			name:     "service actor lacking the scope",
+			policy:   lushauth.ActorPolicy{ServicePolicy: lushauth.ScopePolicy{"users.update"}},
+			expected: lushauth.ActorPolicyError{Err: lushauth.ScopePolicy{"users.update"}},
+		},
+		{
+			name:     "service actor without a service policy",
+			policy:   lushauth.ActorPolicy{Policy: lushauth.RolePolicy{"support"}},
+			expected: lushauth.ActorPolicyError{Err: errors.New("services can not act on behalf of the consumer")},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.policy.PermitResource(ctx, lushauth.ConsumerFromContext(ctx), nil)
+			test.Equals(t, c.expected, err)
+		})
+	}
+	_, isService := lushauth.ServiceFromContext(ctx)
+	test.Equals(t, false, isService)
+}
+
+func TestActorPolicy_NestedActor(t *testing.T) {
+	support := &lushauth.Actor{Subject: Support.UUID, Consumer: Support}
+	delegated, err := lushauth.NewClaimsForDelegation("Test", batchJob, Guest, support)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := lushauth.ContextWithClaims(context.Background(), delegated)
+	cases := []struct {
+		name     string
+		policy   lushauth.ActorPolicy
+		expected error
+	}{
+		{
+			name: "permitted service and permitted consumer",
+			policy: lushauth.ActorPolicy{
+				Policy:        lushauth.RolePolicy{"support"},
+				ServicePolicy: lushauth.ScopePolicy{"stock.update"},
+			},
+		},
+		{
+			name: "permitted service and consumer lacking the role",
+			policy: lushauth.ActorPolicy{
+				Policy:        lushauth.RolePolicy{"admin"},
+				ServicePolicy: lushauth.ScopePolicy{"stock.update"},
+			},
+			expected: lushauth.ActorPolicyError{Err: lushauth.RolePolicy{"admin"}},
+		},
+		{
+			name:     "permitted service without a consumer policy",
+			policy:   lushauth.ActorPolicy{ServicePolicy: lushauth.ScopePolicy{"stock.update"}},
+			expected: lushauth.ActorPolicyError{Err: errors.New("consumers can not act on behalf of the consumer")},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.policy.PermitResource(ctx, lushauth.ConsumerFromContext(ctx), nil)
+			test.Equals(t, c.expected, err)
+		})
+	}
+}
@@ -99,7 +99,22 @@ type Claims struct {
 	IssuedAt  int64 `json:"iat,omitempty"`
 	NotBefore int64 `json:"nbf,omitempty"`
 
+	// ParentID is the id (JTI) of the claims these claims were refreshed from.
+	ParentID string `json:"parent_jti,omitempty"`
+
+	// AuthTime is when the consumer originally authenticated, which is kept when the claims are refreshed.
+	AuthTime int64 `json:"auth_time,omitempty"`
+
 	Consumer Consumer `json:"consumer"`
+
+	// Service is set instead of the consumer when the claims are for a machine consumer.
+	Service *Service `json:"service,omitempty"`
+
+	// Actor is set when another party is acting on behalf of the consumer.
+	Actor *Actor `json:"act,omitempty"`
+
+	// Extra holds typed private claims for uses specific to a service.
+	Extra ExtraClaims `json:"ext,omitempty"`
 }
 
 // Valid validates time based claims (EXP, IAT, NBF) as well as the identifiers (ISS, JTI).
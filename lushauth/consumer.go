@@ -26,20 +26,43 @@ type Consumer struct {
 	Markets []Market `json:"markets"`
 }
 
+// AnonymousUUID is the UUID of the anonymous consumer, which is the nil UUID.
+const AnonymousUUID = "00000000-0000-0000-0000-000000000000"
+
+// AnonymousConsumer returns the consumer of requests made without credentials.
+// Unlike the zero value it can be told apart from a missing consumer with IsAnonymous.
+func AnonymousConsumer() Consumer {
+	return Consumer{
+		UUID:    AnonymousUUID,
+		Grants:  []string{},
+		Roles:   []string{},
+		Needs:   []string{},
+		Markets: []Market{},
+	}
+}
+
+// IsAnonymous checks if a consumer is the anonymous consumer.
+func (c Consumer) IsAnonymous() bool {
+	return c.UUID == AnonymousUUID
+}
+
 // Market represents a market attached to an API user.
 type Market struct {
 	ID    string   `json:"id"`
 	Roles []string `json:"roles"`
+	// Grants are any specific, given permissions for a user within the market.
+	// e.g. tills.close or products.read
+	Grants []string `json:"grants,omitempty"`
 }
 
-// HasAnyGrant checks if a consumer possess any of a given set of grants
+// HasAnyGrant checks if a consumer possess any of a given set of grants, taking wildcards into account.
 func (c *Consumer) HasAnyGrant(grants ...string) bool {
-	return hasAny(c.Grants, grants...)
+	return hasAnyGrant(c.Grants, grants...)
 }
 
-// HasNoMatchingGrant checks if a consumer is missing any of a given set of grants
+// HasNoMatchingGrant checks if a consumer is missing any of a given set of grants, taking wildcards into account.
 func (c Consumer) HasNoMatchingGrant(grants ...string) bool {
-	return hasNoMatching(c.Grants, grants...)
+	return !hasAnyGrant(c.Grants, grants...)
 }
 
 // HasAnyRole checks if a consumer possess any of a given set of roles
@@ -82,6 +105,16 @@ func (c Consumer) HasAnyMarketRole(id string, roles ...string) bool {
 	return false
 }
 
+// HasAnyMarketGrant checks if a user has any grant in a given market, taking wildcards into account.
+func (c Consumer) HasAnyMarketGrant(id string, grants ...string) bool {
+	for _, m := range c.Markets {
+		if m.ID == id {
+			return hasAnyGrant(m.Grants, grants...)
+		}
+	}
+	return false
+}
+
 // hasAny checks if a set contains any of the given members.
 func hasAny(set []string, members ...string) bool {
 	for _, member := range members {
@@ -1,6 +1,8 @@
 package lushauth_test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -129,3 +131,61 @@ func TestConsumer_HasAnyMarketRole(t *testing.T) {
 		test.Equals(t, false, consumer.HasAnyMarketRole("se", "two"))
 	})
 }
+
+func TestConsumer_HasAnyMarketGrant(t *testing.T) {
+	consumer := &lushauth.Consumer{
+		Markets: []lushauth.Market{
+			{
+				ID:     "gb",
+				Grants: []string{"tills.close", "tills.read"},
+			},
+			{
+				ID:     "de",
+				Grants: []string{"tills.read"},
+			},
+		},
+	}
+	t.Run("when user has grant in 'gb' market", func(t *testing.T) {
+		test.Equals(t, true, consumer.HasAnyMarketGrant("gb", "tills.close"))
+	})
+	t.Run("when user doesn't have grant in 'de' market", func(t *testing.T) {
+		test.Equals(t, false, consumer.HasAnyMarketGrant("de", "tills.close"))
+	})
+	t.Run("when user has grant in 'de' market", func(t *testing.T) {
+		test.Equals(t, true, consumer.HasAnyMarketGrant("de", "tills.close", "tills.read"))
+	})
+	t.Run("when user doesn't belong to the market at all", func(t *testing.T) {
+		test.Equals(t, false, consumer.HasAnyMarketGrant("se", "tills.read"))
+	})
+}
+
+func TestMarket_JSON(t *testing.T) {
+	t.Run("when decoding a market without grants", func(t *testing.T) {
+		var market lushauth.Market
+		if err := json.Unmarshal([]byte(`{"id":"gb","roles":["staff"]}`), &market); err != nil {
+			t.Fatal(err)
+		}
+		test.Equals(t, lushauth.Market{ID: "gb", Roles: []string{"staff"}}, market)
+	})
+	t.Run("when encoding a market without grants", func(t *testing.T) {
+		b, err := json.Marshal(lushauth.Market{ID: "gb", Roles: []string{"staff"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Equals(t, `{"id":"gb","roles":["staff"]}`, string(b))
+	})
+	t.Run("when encoding a market with grants", func(t *testing.T) {
+		b, err := json.Marshal(lushauth.Market{ID: "gb", Roles: []string{"staff"}, Grants: []string{"tills.close"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Equals(t, `{"id":"gb","roles":["staff"],"grants":["tills.close"]}`, string(b))
+	})
+}
+
+func TestConsumer_IsAnonymous(t *testing.T) {
+	test.Equals(t, true, lushauth.AnonymousConsumer().IsAnonymous())
+	test.Equals(t, false, lushauth.Consumer{}.IsAnonymous())
+	test.Equals(t, false, consumer.IsAnonymous())
+	test.Equals(t, false, lushauth.ConsumerFromContext(context.Background()).IsAnonymous())
+}
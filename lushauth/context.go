@@ -8,6 +8,9 @@ type key int
 
 const (
 	consumerKey key = iota
+	serviceKey
+	actorKey
+	extraKey
 )
 
 // ContextWithConsumer takes a context and a service consumer and returns a new context with the consumer embedded.
@@ -22,3 +25,40 @@ func ConsumerFromContext(ctx context.Context) Consumer {
 	}
 	return Consumer{}
 }
+
+// ContextWithService takes a context and a service and returns a new context with the service embedded.
+func ContextWithService(parent context.Context, service Service) context.Context {
+	return context.WithValue(parent, serviceKey, service)
+}
+
+// ServiceFromContext extracts the service from the supplied context and reports whether there was one.
+func ServiceFromContext(ctx context.Context) (Service, bool) {
+	s, ok := ctx.Value(serviceKey).(Service)
+	return s, ok
+}
+
+// ContextWithClaims takes a context and a set of claims and returns a new context with the principals of the claims embedded.
+func ContextWithClaims(parent context.Context, claims Claims) context.Context {
+	ctx := ContextWithConsumer(parent, claims.Consumer)
+	if claims.Service != nil {
+		ctx = ContextWithService(ctx, *claims.Service)
+	}
+	if claims.Actor != nil {
+		ctx = ContextWithActor(ctx, *claims.Actor)
+	}
+	if claims.Extra != nil {
+		ctx = ContextWithExtraClaims(ctx, claims.Extra)
+	}
+	return ctx
+}
+
+// ContextWithActor takes a context and an actor and returns a new context with the actor embedded.
+func ContextWithActor(parent context.Context, actor Actor) context.Context {
+	return context.WithValue(parent, actorKey, actor)
+}
+
+// ActorFromContext extracts the actor acting on behalf of the consumer from the supplied context and reports whether there was one.
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	a, ok := ctx.Value(actorKey).(Actor)
+	return a, ok
+}
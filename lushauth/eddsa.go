@@ -0,0 +1,57 @@
+package lushauth
+
+import (
+	"crypto/ed25519"
+	"errors"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var (
+	// ErrEdDSAVerification happens when an EdDSA signature does not match the signed content.
+	ErrEdDSAVerification = errors.New("crypto/ed25519: verification error")
+
+	// SigningMethodEdDSA is the EdDSA signing method using Ed25519 keys, as referenced at
+	// https://tools.ietf.org/html/rfc8037#section-3.1
+	SigningMethodEdDSA = &SigningMethodEd25519{}
+)
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+// SigningMethodEd25519 implements the EdDSA signing method for the jwt-go library.
+// Expects ed25519.PrivateKey for signing and ed25519.PublicKey for verification.
+type SigningMethodEd25519 struct{}
+
+// Alg returns the name of the algorithm as used in the alg header of a token.
+func (m *SigningMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+// Verify checks the signature of the signing string against an ed25519 public key.
+func (m *SigningMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	pk, ok := key.(ed25519.PublicKey)
+	if !ok || len(pk) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pk, []byte(signingString), sig) {
+		return ErrEdDSAVerification
+	}
+	return nil
+}
+
+// Sign signs the signing string with an ed25519 private key.
+func (m *SigningMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	sk, ok := key.(ed25519.PrivateKey)
+	if !ok || len(sk) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(sk, []byte(signingString))), nil
+}
@@ -0,0 +1,137 @@
+package lushauth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Decision describes the outcome of evaluating a policy against a consumer.
+type Decision struct {
+	// Policy describes the evaluated policy in the policy text format.
+	Policy string `json:"policy"`
+	// Permitted is whether the policy permitted the consumer.
+	Permitted bool `json:"permitted"`
+	// Reason is why the policy did not permit the consumer.
+	Reason string `json:"reason,omitempty"`
+	// Decisions are the outcomes of every policy the policy is made up of.
+	Decisions []Decision `json:"decisions,omitempty"`
+}
+
+// String renders the decision and all of its sub decisions as an indented tree.
+func (d Decision) String() string {
+	var sb strings.Builder
+	d.render(&sb, 0)
+	return sb.String()
+}
+
+func (d Decision) render(sb *strings.Builder, depth int) {
+	outcome := "deny"
+	if d.Permitted {
+		outcome = "permit"
+	}
+	fmt.Fprintf(sb, "%s%s %s", strings.Repeat("  ", depth), outcome, d.Policy)
+	if d.Reason != "" {
+		fmt.Fprintf(sb, ": %s", d.Reason)
+	}
+	sb.WriteString("\n")
+	for _, sub := range d.Decisions {
+		sub.render(sb, depth+1)
+	}
+}
+
+// Explainer defines the behavior of a policy that can explain how it reached a decision.
+type Explainer interface {
+	Explain(c Consumer) Decision
+}
+
+// Explain evaluates every part of a policy against a consumer and returns the full decision.
+func Explain(p Permitter, c Consumer) Decision {
+	if e, ok := p.(Explainer); ok {
+		return e.Explain(c)
+	}
+	return decide(describePolicy(p), p.Permit(c))
+}
+
+// decide creates a decision for a single policy outcome.
+func decide(policy string, err error) Decision {
+	d := Decision{Policy: policy, Permitted: err == nil}
+	if err != nil {
+		d.Reason = err.Error()
+	}
+	return d
+}
+
+// Explain evaluates every policy and permits the consumer if any of them did.
+func (p AnyPolicy) Explain(c Consumer) Decision {
+	d := Decision{Policy: "any", Permitted: len(p) == 0}
+	for _, policy := range p {
+		sub := Explain(policy, c)
+		d.Permitted = d.Permitted || sub.Permitted
+		d.Decisions = append(d.Decisions, sub)
+	}
+	if !d.Permitted {
+		d.Reason = "none of the policies permitted access"
+	}
+	return d
+}
+
+// Explain evaluates every policy and permits the consumer if all of them did.
+func (p AllPolicy) Explain(c Consumer) Decision {
+	d := Decision{Policy: "all", Permitted: true}
+	var denied int
+	for _, policy := range p {
+		sub := Explain(policy, c)
+		if !sub.Permitted {
+			denied++
+		}
+		d.Decisions = append(d.Decisions, sub)
+	}
+	if denied > 0 {
+		d.Permitted = false
+		d.Reason = fmt.Sprintf("%d of %d policies did not permit access", denied, len(p))
+	}
+	return d
+}
+
+// Explain evaluates the negated policy and permits the consumer if it did not.
+func (p NotPolicy) Explain(c Consumer) Decision {
+	sub := Explain(p.Policy, c)
+	d := Decision{Policy: "not", Permitted: !sub.Permitted, Decisions: []Decision{sub}}
+	if !d.Permitted {
+		d.Reason = p.Error()
+	}
+	return d
+}
+
+// Explain evaluates the policy against the effective roles and grants of the consumer.
+func (p CataloguePolicy) Explain(c Consumer) Decision {
+	sub := Explain(p.Policy, p.Catalogue.Expand(c))
+	d := Decision{Policy: "catalogue", Permitted: sub.Permitted, Decisions: []Decision{sub}}
+	if !d.Permitted {
+		d.Reason = sub.Reason
+	}
+	return d
+}
+
+// describePolicy describes a policy in the policy text format.
+func describePolicy(p Permitter) string {
+	switch policy := p.(type) {
+	case UserPolicy:
+		return describe("user", policy...)
+	case RolePolicy:
+		return describe("role", policy...)
+	case GrantPolicy:
+		return describe("grant", policy...)
+	case CompiledGrantPolicy:
+		return describe("grant", policy.GrantPolicy...)
+	case MarketPolicy:
+		return describe("market", append([]string{policy.ID}, policy.Roles...)...)
+	case MarketGrantPolicy:
+		return describe("market_grant", append([]string{policy.ID}, policy.Grants...)...)
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+func describe(name string, values ...string) string {
+	return fmt.Sprintf("%s(%s)", name, strings.Join(quote(values...), ", "))
+}
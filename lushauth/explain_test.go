@@ -0,0 +1,64 @@
+package lushauth_test
+
+import (
+	"encoding/json"
+	"log"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+)
+
+func ExampleExplain() {
+	policy := lushauth.AnyPolicy{
+		lushauth.MarketPolicy{ID: "gb", Roles: []string{"digital_manager"}},
+		lushauth.RolePolicy{"admin"},
+	}
+	decision := lushauth.Explain(policy, consumer)
+	log.Println(decision)
+}
+
+func TestExplain(t *testing.T) {
+	policy := lushauth.AnyPolicy{
+		lushauth.MarketPolicy{ID: "se", Roles: []string{"manager"}},
+		lushauth.AllPolicy{
+			lushauth.RolePolicy{"staff"},
+			lushauth.NotPolicy{Policy: lushauth.GrantPolicy{"users.delete"}},
+		},
+	}
+	t.Run("when a consumer is denied", func(t *testing.T) {
+		decision := lushauth.Explain(policy, Admin)
+		test.Equals(t, false, decision.Permitted)
+		test.Equals(t, policy.Permit(Admin) == nil, decision.Permitted)
+		test.Equals(t, `deny any: none of the policies permitted access
+  deny market("se", "manager"): need to be a part of the "se" market with any of the "manager" market roles
+  deny all: 2 of 2 policies did not permit access
+    deny role("staff"): need to have any of the "staff" roles
+    deny not: need to not be permitted by the negated policy
+      permit grant("users.delete")
+`, decision.String())
+	})
+	t.Run("when a consumer is permitted", func(t *testing.T) {
+		decision := lushauth.Explain(policy, Manager)
+		test.Equals(t, true, decision.Permitted)
+		test.Equals(t, policy.Permit(Manager) == nil, decision.Permitted)
+		test.Equals(t, `permit any
+  permit market("se", "manager")
+  permit all
+    permit role("staff")
+    permit not
+      deny grant("users.delete"): need to have any of the "users.delete" grants
+`, decision.String())
+	})
+	t.Run("when rendering as json", func(t *testing.T) {
+		b, err := json.Marshal(lushauth.Explain(lushauth.AllPolicy{lushauth.UserPolicy{GuestID}}, Staff))
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Equals(t, `{"policy":"all","permitted":false,"reason":"1 of 1 policies did not permit access","decisions":[{"policy":"user(\"`+GuestID+`\")","permitted":false,"reason":"need to be a specific user"}]}`, string(b))
+	})
+	t.Run("when using a catalogue", func(t *testing.T) {
+		decision := lushauth.Explain(lushauth.CataloguePolicy{Catalogue: catalogue, Policy: lushauth.RolePolicy{"staff"}}, Admin)
+		test.Equals(t, "permit catalogue\n  permit role(\"staff\")\n", decision.String())
+	})
+}
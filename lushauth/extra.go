@@ -0,0 +1,111 @@
+package lushauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// ExtraClaim represents a typed private claim that can be attached to a set of claims.
+// The claim is stored as JSON under its name, so it needs to be encodable with the encoding/json package.
+type ExtraClaim interface {
+	ClaimName() string
+}
+
+// ExtraClaimValidator is implemented by extra claims that validate themselves after being decoded.
+type ExtraClaimValidator interface {
+	Valid() error
+}
+
+// ExtraClaims hold the private claims attached to a set of claims, keyed by their name.
+type ExtraClaims map[string]json.RawMessage
+
+// Set encodes an extra claim and stores it under its name.
+func (x *ExtraClaims) Set(e ExtraClaim) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return JWTExtraClaimError{Name: e.ClaimName(), Err: err}
+	}
+	if *x == nil {
+		*x = make(ExtraClaims)
+	}
+	(*x)[e.ClaimName()] = raw
+	return nil
+}
+
+// Has reports whether an extra claim is stored under the name.
+func (x ExtraClaims) Has(name string) bool {
+	_, ok := x[name]
+	return ok
+}
+
+// Get decodes the extra claim stored under its name into e, which needs to be a pointer.
+// The claim is validated when it implements the ExtraClaimValidator interface.
+func (x ExtraClaims) Get(e ExtraClaim) error {
+	name := e.ClaimName()
+	raw, ok := x[name]
+	if !ok {
+		return JWTExtraClaimMissingError{Name: name}
+	}
+	if err := json.Unmarshal(raw, e); err != nil {
+		return JWTExtraClaimError{Name: name, Err: err}
+	}
+	if v, ok := e.(ExtraClaimValidator); ok {
+		if err := v.Valid(); err != nil {
+			return JWTExtraClaimError{Name: name, Err: err}
+		}
+	}
+	return nil
+}
+
+// Validate checks that every one of the given extra claims is present, can be decoded and is valid.
+// The given claims are only used for their type and are left untouched.
+func (x ExtraClaims) Validate(claims ...ExtraClaim) error {
+	for _, claim := range claims {
+		t := reflect.TypeOf(claim)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		e, ok := reflect.New(t).Interface().(ExtraClaim)
+		if !ok {
+			return JWTExtraClaimError{Name: claim.ClaimName(), Err: fmt.Errorf("%v is not an extra claim", t)}
+		}
+		if err := x.Get(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JWTExtraClaimMissingError happens when an extra claim is not present in a set of claims.
+type JWTExtraClaimMissingError struct {
+	Name string
+}
+
+func (e JWTExtraClaimMissingError) Error() string {
+	return fmt.Sprintf("missing extra claim: %s", e.Name)
+}
+
+// JWTExtraClaimError happens when an extra claim could not be encoded, decoded or validated.
+type JWTExtraClaimError struct {
+	Name string
+	Err  error
+}
+
+func (e JWTExtraClaimError) Error() string {
+	return fmt.Sprintf("invalid extra claim %s: %v", e.Name, e.Err)
+}
+
+// ContextWithExtraClaims takes a context and a set of extra claims and returns a new context with the extra claims embedded.
+func ContextWithExtraClaims(parent context.Context, extra ExtraClaims) context.Context {
+	return context.WithValue(parent, extraKey, extra)
+}
+
+// ExtraClaimsFromContext extracts the extra claims from the supplied context.
+func ExtraClaimsFromContext(ctx context.Context) ExtraClaims {
+	if x, ok := ctx.Value(extraKey).(ExtraClaims); ok {
+		return x
+	}
+	return ExtraClaims{}
+}
@@ -0,0 +1,124 @@
+package lushauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+type StoreClaim struct {
+	StoreID string `json:"store_id"`
+	Till    int    `json:"till"`
+}
+
+func (s StoreClaim) ClaimName() string {
+	return "store"
+}
+
+func (s StoreClaim) Valid() error {
+	if s.StoreID == "" {
+		return errors.New("store id is required")
+	}
+	return nil
+}
+
+type TierClaim string
+
+func (t TierClaim) ClaimName() string {
+	return "tier"
+}
+
+func ExampleExtraClaims() {
+	claims, _ := lushauth.NewClaimsForConsumer("Test", consumer)
+	claims.Extra.Set(StoreClaim{StoreID: "gb-london-oxford-st", Till: 2})
+
+	// The extra claims can be read from the context in a handler behind the auth middleware.
+	ctx := lushauth.ContextWithClaims(context.Background(), claims)
+	var store StoreClaim
+	if err := lushauth.ExtraClaimsFromContext(ctx).Get(&store); err != nil {
+		return
+	}
+}
+
+func TestExtraClaims_SignAndParse(t *testing.T) {
+	claims := validClaims
+	test.Equals(t, nil, claims.Extra.Set(StoreClaim{StoreID: "gb-london", Till: 2}))
+	test.Equals(t, nil, claims.Extra.Set(TierClaim("gold")))
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, &claims).SignedString(rsaPriv)
+	test.Equals(t, nil, err)
+
+	var parsed lushauth.Claims
+	_, err = jwt.ParseWithClaims(token, &parsed, lushauth.RSAKeyFunc(public))
+	test.Equals(t, nil, err)
+
+	var store StoreClaim
+	test.Equals(t, nil, parsed.Extra.Get(&store))
+	test.Equals(t, StoreClaim{StoreID: "gb-london", Till: 2}, store)
+	var tier TierClaim
+	test.Equals(t, nil, parsed.Extra.Get(&tier))
+	test.Equals(t, TierClaim("gold"), tier)
+	test.Equals(t, lushauth.ExtraClaims(nil), validClaims.Extra)
+}
+
+func TestExtraClaims_Get(t *testing.T) {
+	cases := []struct {
+		name        string
+		extra       lushauth.ExtraClaims
+		expected    StoreClaim
+		expectedErr error
+	}{
+		{
+			name:     "valid claim",
+			extra:    lushauth.ExtraClaims{"store": json.RawMessage(`{"store_id":"gb-london"}`)},
+			expected: StoreClaim{StoreID: "gb-london"},
+		},
+		{
+			name:        "missing claim",
+			extra:       lushauth.ExtraClaims{},
+			expectedErr: lushauth.JWTExtraClaimMissingError{Name: "store"},
+		},
+		{
+			name:        "invalid claim",
+			extra:       lushauth.ExtraClaims{"store": json.RawMessage(`{"till":1}`)},
+			expected:    StoreClaim{Till: 1},
+			expectedErr: lushauth.JWTExtraClaimError{Name: "store", Err: errors.New("store id is required")},
+		},
+		{
+			name:        "malformed claim",
+			extra:       lushauth.ExtraClaims{"store": json.RawMessage(`"gb-london"`)},
+			expectedErr: errors.New("invalid extra claim store: json: cannot unmarshal string into Go value of type lushauth_test.StoreClaim"),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var store StoreClaim
+			err := c.extra.Get(&store)
+			test.Equals(t, c.expectedErr, err)
+			test.Equals(t, c.expected, store)
+		})
+	}
+}
+
+func TestExtraClaims_Validate(t *testing.T) {
+	var extra lushauth.ExtraClaims
+	extra.Set(StoreClaim{StoreID: "gb-london"})
+	test.Equals(t, nil, extra.Validate(StoreClaim{}))
+	test.Equals(t, nil, extra.Validate(&StoreClaim{}))
+	test.Equals(t, lushauth.JWTExtraClaimMissingError{Name: "tier"}, extra.Validate(StoreClaim{}, TierClaim("")))
+	extra.Set(StoreClaim{})
+	test.Equals(t, lushauth.JWTExtraClaimError{Name: "store", Err: errors.New("store id is required")}, extra.Validate(StoreClaim{}))
+}
+
+func TestExtraClaimsFromContext(t *testing.T) {
+	claims := validClaims
+	claims.Extra.Set(TierClaim("gold"))
+	ctx := lushauth.ContextWithClaims(context.Background(), claims)
+	test.Equals(t, claims.Extra, lushauth.ExtraClaimsFromContext(ctx))
+	test.Equals(t, consumer, lushauth.ConsumerFromContext(ctx))
+	test.Equals(t, lushauth.ExtraClaims{}, lushauth.ExtraClaimsFromContext(context.Background()))
+}
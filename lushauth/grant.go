@@ -0,0 +1,104 @@
+package lushauth
+
+import (
+	"strings"
+)
+
+const (
+	// GrantWildcard matches any single segment of a grant, or all remaining segments when used last.
+	// e.g. "products.*" matches "products.create" and "*.read" matches "pages.read"
+	GrantWildcard = "*"
+
+	grantSeparator = '.'
+)
+
+// MatchGrant checks if a granted grant matches a required grant.
+// Wildcards are only honoured in the granted grant, so that a wildcard in a required grant only matches the same wildcard.
+func MatchGrant(granted, required string) bool {
+	if granted == required {
+		return true
+	}
+	for {
+		gs, grest, gmore := cutGrant(granted)
+		rs, rrest, rmore := cutGrant(required)
+		if gs == GrantWildcard && !gmore {
+			return true
+		}
+		if gs != rs && gs != GrantWildcard {
+			return false
+		}
+		if !gmore || !rmore {
+			return gmore == rmore
+		}
+		granted, required = grest, rrest
+	}
+}
+
+// cutGrant slices a grant around its first separator.
+func cutGrant(grant string) (segment, rest string, more bool) {
+	if i := strings.IndexByte(grant, grantSeparator); i >= 0 {
+		return grant[:i], grant[i+1:], true
+	}
+	return grant, "", false
+}
+
+// hasWildcard checks if a grant contains any wildcard segment.
+func hasWildcard(grant string) bool {
+	return strings.Contains(grant, GrantWildcard)
+}
+
+// GrantMatcher is a precompiled set of required grants that granted grants can be matched against without allocating.
+type GrantMatcher struct {
+	exact    map[string]struct{}
+	required []string
+}
+
+// NewGrantMatcher compiles a set of required grants into a matcher.
+func NewGrantMatcher(required ...string) *GrantMatcher {
+	m := &GrantMatcher{
+		exact:    make(map[string]struct{}, len(required)),
+		required: required,
+	}
+	for _, grant := range required {
+		m.exact[grant] = struct{}{}
+	}
+	return m
+}
+
+// Match checks if a granted grant matches any of the required grants in the matcher.
+func (m *GrantMatcher) Match(granted string) bool {
+	if _, ok := m.exact[granted]; ok {
+		return true
+	}
+	if !hasWildcard(granted) {
+		return false
+	}
+	for _, required := range m.required {
+		if MatchGrant(granted, required) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAny checks if any of the granted grants match any of the required grants in the matcher.
+func (m *GrantMatcher) MatchAny(grants ...string) bool {
+	for _, grant := range grants {
+		if m.Match(grant) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyGrant checks if a set of granted grants matches any of the given required grants.
+func hasAnyGrant(set []string, grants ...string) bool {
+	for _, grant := range grants {
+		for _, g := range set {
+			if MatchGrant(g, grant) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package lushauth_test
+
+import (
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+)
+
+func TestMatchGrant(t *testing.T) {
+	cases := []struct {
+		granted, required string
+		expected          bool
+	}{
+		{"products.create", "products.create", true},
+		{"products.create", "products.read", false},
+		{"products.*", "products.create", true},
+		{"products.*", "products.variants.create", true},
+		{"products.*", "products", false},
+		{"products.*", "pages.create", false},
+		{"*.read", "pages.read", true},
+		{"*.read", "pages.create", false},
+		{"*.read", "products.variants.read", false},
+		{"products.*.read", "products.variants.read", true},
+		{"products.*.read", "products.variants.create", false},
+		{"*", "products.create", true},
+		{"products", "products.create", false},
+		{"", "products", false},
+		{"products.create", "products.*", false},
+		{"pages.read", "*.read", false},
+		{"products.create", "*", false},
+		{"products.*", "*.create", false},
+		{"products.*", "products.*", true},
+		{"*", "*", true},
+	}
+	for _, c := range cases {
+		t.Run(c.granted+" for "+c.required, func(t *testing.T) {
+			test.Equals(t, c.expected, lushauth.MatchGrant(c.granted, c.required))
+		})
+	}
+}
+
+func TestGrantMatcher_MatchAny(t *testing.T) {
+	matcher := lushauth.NewGrantMatcher("pages.read", "products.create", "users.delete")
+	cases := []struct {
+		name     string
+		grants   []string
+		expected bool
+	}{
+		{name: "exact grant", grants: []string{"pages.read"}, expected: true},
+		{name: "wildcard grant", grants: []string{"products.*"}, expected: true},
+		{name: "leading wildcard grant", grants: []string{"*.delete"}, expected: true},
+		{name: "wildcard grant for everything", grants: []string{"*"}, expected: true},
+		{name: "grants not matching", grants: []string{"pages.create", "users.read"}, expected: false},
+		{name: "wildcard grant not matching", grants: []string{"orders.*"}, expected: false},
+		{name: "no grants", grants: nil, expected: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			test.Equals(t, c.expected, matcher.MatchAny(c.grants...))
+		})
+	}
+}
+
+func TestGrantMatcher_RequiredWildcard(t *testing.T) {
+	matcher := lushauth.NewGrantMatcher("products.*")
+	test.Equals(t, false, matcher.MatchAny("products.read"))
+	test.Equals(t, true, matcher.MatchAny("products.*"))
+}
+
+func TestGrantMatching_Allocations(t *testing.T) {
+	admin := lushauth.Consumer{Grants: []string{"pages.read", "products.*", "*.delete"}}
+	policy := lushauth.GrantPolicy{"products.create", "users.delete"}.Compile()
+	t.Run("consumer has any grant", func(t *testing.T) {
+		allocs := testing.AllocsPerRun(100, func() {
+			admin.HasAnyGrant("users.delete")
+		})
+		test.Equals(t, float64(0), allocs)
+	})
+	t.Run("compiled grant policy", func(t *testing.T) {
+		allocs := testing.AllocsPerRun(100, func() {
+			policy.Permit(admin)
+		})
+		test.Equals(t, float64(0), allocs)
+	})
+}
+
+func BenchmarkConsumer_HasAnyGrant(b *testing.B) {
+	admin := lushauth.Consumer{Grants: []string{"pages.read", "pages.create", "products.*"}}
+	for i := 0; i < b.N; i++ {
+		admin.HasAnyGrant("products.create")
+	}
+}
+
+func BenchmarkCompiledGrantPolicy_Permit(b *testing.B) {
+	admin := lushauth.Consumer{Grants: []string{"pages.read", "pages.create", "products.*"}}
+	policy := lushauth.GrantPolicy{"users.delete", "products.create"}.Compile()
+	for i := 0; i < b.N; i++ {
+		policy.Permit(admin)
+	}
+}
@@ -0,0 +1,136 @@
+package lushauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// JWTAlgorithmError happens when the signing algorithm of a token cannot be used with the verification key.
+type JWTAlgorithmError struct {
+	Algorithm interface{}
+	Expected  string
+}
+
+func (e JWTAlgorithmError) Error() string {
+	return fmt.Sprintf("unexpected signing method (needs to be %s): %v", e.Expected, e.Algorithm)
+}
+
+// JWTUnknownKeyError happens when there is no verification key for the key id of a token.
+type JWTUnknownKeyError struct {
+	KeyID interface{}
+}
+
+func (e JWTUnknownKeyError) Error() string {
+	return fmt.Sprintf("unknown verification key: %v", e.KeyID)
+}
+
+// ECDSAKeyFunc is used with the jwt-go library to validate that a token is using the ECDSA signing algorithm.
+func ECDSAKeyFunc(pk crypto.PublicKey) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+			return pk, JWTAlgorithmError{token.Header["alg"], "ECDSA"}
+		}
+		return pk, nil
+	}
+}
+
+// RSAPSSKeyFunc is used with the jwt-go library to validate that a token is using the RSA-PSS signing algorithm.
+func RSAPSSKeyFunc(pk crypto.PublicKey) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSAPSS); !ok {
+			return pk, JWTAlgorithmError{token.Header["alg"], "RSA-PSS"}
+		}
+		return pk, nil
+	}
+}
+
+// EdDSAKeyFunc is used with the jwt-go library to validate that a token is using the EdDSA signing algorithm.
+func EdDSAKeyFunc(pk crypto.PublicKey) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*SigningMethodEd25519); !ok {
+			return pk, JWTAlgorithmError{token.Header["alg"], "EdDSA"}
+		}
+		return pk, nil
+	}
+}
+
+// VerificationKey is a public key that can be used to verify tokens.
+type VerificationKey struct {
+	// ID is matched against the kid header of a token.
+	// A key without an ID is only used for tokens without a kid header.
+	ID string
+	// Algorithms restricts which signing algorithms are accepted for the key.
+	// When empty, every algorithm matching the type of the key is accepted.
+	// e.g. "RS256", "PS512", "ES256" or "EdDSA"
+	Algorithms []string
+	// Key is the public key: *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+	Key crypto.PublicKey
+}
+
+// accepts checks if the key can be used to verify a token signed with the given method.
+// The check is made against the type of the key so that a public key can never be used
+// as an HMAC secret, regardless of which algorithms have been configured.
+func (k VerificationKey) accepts(method jwt.SigningMethod) bool {
+	if len(k.Algorithms) > 0 && !hasAny(k.Algorithms, method.Alg()) {
+		return false
+	}
+	switch key := k.Key.(type) {
+	case *rsa.PublicKey:
+		switch method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return true
+		}
+	case *ecdsa.PublicKey:
+		if m, ok := method.(*jwt.SigningMethodECDSA); ok {
+			return key.Curve != nil && key.Curve.Params().BitSize == m.CurveBits
+		}
+	case ed25519.PublicKey:
+		_, ok := method.(*SigningMethodEd25519)
+		return ok
+	}
+	return false
+}
+
+// expects describes the signing algorithms accepted for the key.
+func (k VerificationKey) expects() string {
+	if len(k.Algorithms) > 0 {
+		return strings.Join(k.Algorithms, " or ")
+	}
+	switch k.Key.(type) {
+	case *rsa.PublicKey:
+		return "RSA or RSA-PSS"
+	case *ecdsa.PublicKey:
+		return "ECDSA"
+	case ed25519.PublicKey:
+		return "EdDSA"
+	}
+	return fmt.Sprintf("%T", k.Key)
+}
+
+// KeySetFunc is used with the jwt-go library to pick the verification key for a token by its kid header
+// and to validate that the token is signed with an algorithm matching the type of that key.
+func KeySetFunc(keys ...VerificationKey) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		var expected []string
+		for _, key := range keys {
+			if key.ID != kid {
+				continue
+			}
+			if key.accepts(token.Method) {
+				return key.Key, nil
+			}
+			expected = append(expected, key.expects())
+		}
+		if len(expected) == 0 {
+			return nil, JWTUnknownKeyError{token.Header["kid"]}
+		}
+		return nil, JWTAlgorithmError{token.Header["alg"], strings.Join(expected, " or ")}
+	}
+}
@@ -0,0 +1,164 @@
+package lushauth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+
+	"github.com/LUSHDigital/core/test"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func withKeyID(kid string, token *jwt.Token) *jwt.Token {
+	token.Header["kid"] = kid
+	return token
+}
+
+func TestKeyFuncs(t *testing.T) {
+	edPublic, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type Test struct {
+		name        string
+		token       string
+		keyfunc     jwt.Keyfunc
+		expectedErr error
+	}
+	cases := []Test{
+		{
+			name:    "ECDSA key func with ES512 token",
+			token:   must(jwt.NewWithClaims(jwt.SigningMethodES512, &validClaims).SignedString(ecPriv)),
+			keyfunc: lushauth.ECDSAKeyFunc(&ecPriv.PublicKey),
+		},
+		{
+			name:    "ECDSA key func with RS256 token",
+			token:   must(jwt.NewWithClaims(jwt.SigningMethodRS256, &validClaims).SignedString(rsaPriv)),
+			keyfunc: lushauth.ECDSAKeyFunc(&ecPriv.PublicKey),
+			expectedErr: jwt.ValidationError{
+				Inner: lushauth.JWTAlgorithmError{Algorithm: "RS256", Expected: "ECDSA"},
+			},
+		},
+		{
+			name:    "RSA-PSS key func with PS256 token",
+			token:   must(jwt.NewWithClaims(jwt.SigningMethodPS256, &validClaims).SignedString(rsaPriv)),
+			keyfunc: lushauth.RSAPSSKeyFunc(public),
+		},
+		{
+			name:    "RSA-PSS key func with RS256 token",
+			token:   must(jwt.NewWithClaims(jwt.SigningMethodRS256, &validClaims).SignedString(rsaPriv)),
+			keyfunc: lushauth.RSAPSSKeyFunc(public),
+			expectedErr: jwt.ValidationError{
+				Inner: lushauth.JWTAlgorithmError{Algorithm: "RS256", Expected: "RSA-PSS"},
+			},
+		},
+		{
+			name:    "EdDSA key func with EdDSA token",
+			token:   must(jwt.NewWithClaims(lushauth.SigningMethodEdDSA, &validClaims).SignedString(edPriv)),
+			keyfunc: lushauth.EdDSAKeyFunc(edPublic),
+		},
+		{
+			name:    "EdDSA key func with HS256 token",
+			token:   must(jwt.NewWithClaims(jwt.SigningMethodHS256, &validClaims).SignedString([]byte(edPublic))),
+			keyfunc: lushauth.EdDSAKeyFunc(edPublic),
+			expectedErr: jwt.ValidationError{
+				Inner: lushauth.JWTAlgorithmError{Algorithm: "HS256", Expected: "EdDSA"},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var claims lushauth.Claims
+			_, err := jwt.ParseWithClaims(c.token, &claims, c.keyfunc)
+			test.Equals(t, c.expectedErr, err)
+		})
+	}
+}
+
+func TestKeySetFunc(t *testing.T) {
+	edPublic, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyfunc := lushauth.KeySetFunc(
+		lushauth.VerificationKey{Key: public},
+		lushauth.VerificationKey{ID: "rsa-pss", Key: public, Algorithms: []string{"PS256"}},
+		lushauth.VerificationKey{ID: "ecdsa", Key: &p256Priv.PublicKey},
+		lushauth.VerificationKey{ID: "eddsa", Key: edPublic},
+	)
+	type Test struct {
+		name        string
+		token       *jwt.Token
+		key         interface{}
+		expectedErr error
+	}
+	cases := []Test{
+		{
+			name:  "RS256 token without key id",
+			token: jwt.NewWithClaims(jwt.SigningMethodRS256, &validClaims),
+			key:   rsaPriv,
+		},
+		{
+			name:  "PS256 token with rsa-pss key id",
+			token: withKeyID("rsa-pss", jwt.NewWithClaims(jwt.SigningMethodPS256, &validClaims)),
+			key:   rsaPriv,
+		},
+		{
+			name:  "PS512 token with rsa-pss key id not allowing the algorithm",
+			token: withKeyID("rsa-pss", jwt.NewWithClaims(jwt.SigningMethodPS512, &validClaims)),
+			key:   rsaPriv,
+			expectedErr: jwt.ValidationError{
+				Inner: lushauth.JWTAlgorithmError{Algorithm: "PS512", Expected: "PS256"},
+			},
+		},
+		{
+			name:  "ES256 token with ecdsa key id",
+			token: withKeyID("ecdsa", jwt.NewWithClaims(jwt.SigningMethodES256, &validClaims)),
+			key:   p256Priv,
+		},
+		{
+			name:  "ES512 token with ecdsa key id for a different curve",
+			token: withKeyID("ecdsa", jwt.NewWithClaims(jwt.SigningMethodES512, &validClaims)),
+			key:   ecPriv,
+			expectedErr: jwt.ValidationError{
+				Inner: lushauth.JWTAlgorithmError{Algorithm: "ES512", Expected: "ECDSA"},
+			},
+		},
+		{
+			name:  "EdDSA token with eddsa key id",
+			token: withKeyID("eddsa", jwt.NewWithClaims(lushauth.SigningMethodEdDSA, &validClaims)),
+			key:   edPriv,
+		},
+		{
+			name:  "HS256 token signed with the public key",
+			token: withKeyID("eddsa", jwt.NewWithClaims(jwt.SigningMethodHS256, &validClaims)),
+			key:   []byte(edPublic),
+			expectedErr: jwt.ValidationError{
+				Inner: lushauth.JWTAlgorithmError{Algorithm: "HS256", Expected: "EdDSA"},
+			},
+		},
+		{
+			name:  "RS256 token with unknown key id",
+			token: withKeyID("unknown", jwt.NewWithClaims(jwt.SigningMethodRS256, &validClaims)),
+			key:   rsaPriv,
+			expectedErr: jwt.ValidationError{
+				Inner: lushauth.JWTUnknownKeyError{KeyID: "unknown"},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var claims lushauth.Claims
+			_, err := jwt.ParseWithClaims(must(c.token.SignedString(c.key)), &claims, keyfunc)
+			test.Equals(t, c.expectedErr, err)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package lushauthtest
+
+import (
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/uuid"
+)
+
+// ConsumerBuilder builds consumers for tests.
+type ConsumerBuilder struct {
+	consumer lushauth.Consumer
+}
+
+// NewConsumer creates a consumer builder for a consumer with a random UUID and no roles, grants, needs or markets.
+func NewConsumer() *ConsumerBuilder {
+	return &ConsumerBuilder{
+		consumer: lushauth.Consumer{
+			ID:        1,
+			UUID:      uuid.Must(uuid.NewV4()).String(),
+			FirstName: "John",
+			LastName:  "Doe",
+			Language:  "en",
+			Grants:    []string{},
+			Roles:     []string{},
+			Needs:     []string{},
+			Markets:   []lushauth.Market{},
+		},
+	}
+}
+
+// WithID sets the id of the consumer.
+func (b *ConsumerBuilder) WithID(id int64) *ConsumerBuilder {
+	b.consumer.ID = id
+	return b
+}
+
+// WithUUID sets the UUID of the consumer.
+func (b *ConsumerBuilder) WithUUID(uuid string) *ConsumerBuilder {
+	b.consumer.UUID = uuid
+	return b
+}
+
+// WithName sets the first and last name of the consumer.
+func (b *ConsumerBuilder) WithName(first, last string) *ConsumerBuilder {
+	b.consumer.FirstName = first
+	b.consumer.LastName = last
+	return b
+}
+
+// WithLanguage sets the preferred language of the consumer.
+func (b *ConsumerBuilder) WithLanguage(language string) *ConsumerBuilder {
+	b.consumer.Language = language
+	return b
+}
+
+// WithRoles adds roles to the consumer.
+func (b *ConsumerBuilder) WithRoles(roles ...string) *ConsumerBuilder {
+	b.consumer.Roles = append(b.consumer.Roles, roles...)
+	return b
+}
+
+// WithGrants adds grants to the consumer.
+func (b *ConsumerBuilder) WithGrants(grants ...string) *ConsumerBuilder {
+	b.consumer.Grants = append(b.consumer.Grants, grants...)
+	return b
+}
+
+// WithNeeds adds needs to the consumer.
+func (b *ConsumerBuilder) WithNeeds(needs ...string) *ConsumerBuilder {
+	b.consumer.Needs = append(b.consumer.Needs, needs...)
+	return b
+}
+
+// WithMarket adds a market with the given market roles to the consumer.
+func (b *ConsumerBuilder) WithMarket(id string, roles ...string) *ConsumerBuilder {
+	b.consumer.Markets = append(b.consumer.Markets, lushauth.Market{ID: id, Roles: roles})
+	return b
+}
+
+// WithMarketGrants adds grants to a market of the consumer, adding the market when the consumer does not belong to it yet.
+func (b *ConsumerBuilder) WithMarketGrants(id string, grants ...string) *ConsumerBuilder {
+	for i, m := range b.consumer.Markets {
+		if m.ID == id {
+			b.consumer.Markets[i].Grants = append(m.Grants, grants...)
+			return b
+		}
+	}
+	b.consumer.Markets = append(b.consumer.Markets, lushauth.Market{ID: id, Roles: []string{}, Grants: grants})
+	return b
+}
+
+// Build returns a copy of the consumer.
+func (b *ConsumerBuilder) Build() lushauth.Consumer {
+	c := b.consumer
+	c.Grants = append([]string{}, c.Grants...)
+	c.Roles = append([]string{}, c.Roles...)
+	c.Needs = append([]string{}, c.Needs...)
+	c.Markets = make([]lushauth.Market, len(b.consumer.Markets))
+	for i, m := range b.consumer.Markets {
+		c.Markets[i] = lushauth.Market{ID: m.ID, Roles: append([]string{}, m.Roles...)}
+		if m.Grants != nil {
+			c.Markets[i].Grants = append([]string{}, m.Grants...)
+		}
+	}
+	return c
+}
@@ -0,0 +1,42 @@
+package lushauthtest_test
+
+import (
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core-lush/lushauth/lushauthtest"
+	"github.com/LUSHDigital/core/test"
+)
+
+func TestConsumerBuilder(t *testing.T) {
+	builder := lushauthtest.NewConsumer().
+		WithID(2).
+		WithUUID("5d4b32f9-5954-41c3-a470-7d76317635a7").
+		WithName("Jane", "Doe").
+		WithLanguage("sv").
+		WithRoles("staff").
+		WithGrants("products.read").
+		WithNeeds("accept_terms").
+		WithMarket("gb", "digital_manager").
+		WithMarketGrants("gb", "tills.close").
+		WithMarketGrants("se", "tills.open")
+	expected := lushauth.Consumer{
+		ID:        2,
+		UUID:      "5d4b32f9-5954-41c3-a470-7d76317635a7",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Language:  "sv",
+		Roles:     []string{"staff"},
+		Grants:    []string{"products.read"},
+		Needs:     []string{"accept_terms"},
+		Markets: []lushauth.Market{
+			{ID: "gb", Roles: []string{"digital_manager"}, Grants: []string{"tills.close"}},
+			{ID: "se", Roles: []string{}, Grants: []string{"tills.open"}},
+		},
+	}
+	consumer := builder.Build()
+	test.Equals(t, expected, consumer)
+
+	builder.WithRoles("admin")
+	test.Equals(t, []string{"staff"}, consumer.Roles)
+}
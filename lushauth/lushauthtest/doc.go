@@ -0,0 +1,2 @@
+// Package lushauthtest provides helpers for testing access rules built with the lushauth package.
+package lushauthtest
@@ -0,0 +1,128 @@
+package lushauthtest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"sync"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	jwt "github.com/dgrijalva/jwt-go"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Issuer is the issuer of the claims created by the key broker.
+	Issuer = "lushauthtest"
+
+	bitSize = 2048
+
+	// metaAuthTokenKey is the gRPC metadata key that the auth middlewares read the token from.
+	metaAuthTokenKey = "auth-token"
+)
+
+// KeyBroker is a fake key broker which can sign tokens for the public key it brokers.
+// It satisfies the CopierRenewer interface of the lushauthmw package.
+type KeyBroker struct {
+	mu       sync.RWMutex
+	private  *rsa.PrivateKey
+	renewals int
+}
+
+// NewKeyBroker creates a key broker for an RSA private key.
+func NewKeyBroker(private *rsa.PrivateKey) *KeyBroker {
+	return &KeyBroker{private: private}
+}
+
+// MustNewKeyBroker creates a key broker with a random RSA key and will panic on failure.
+func MustNewKeyBroker() *KeyBroker {
+	private, err := rsa.GenerateKey(rand.Reader, bitSize)
+	if err != nil {
+		panic(err)
+	}
+	return NewKeyBroker(private)
+}
+
+// Copy returns a shallow copy of the RSA public key.
+func (b *KeyBroker) Copy() rsa.PublicKey {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.private.PublicKey
+}
+
+// Renew records that the key was asked to be renewed.
+func (b *KeyBroker) Renew() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.renewals++
+}
+
+// Renewals returns the number of times the key was asked to be renewed.
+func (b *KeyBroker) Renewals() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.renewals
+}
+
+// Rotate replaces the RSA key, after which tokens signed with the previous key are no longer valid.
+func (b *KeyBroker) Rotate(private *rsa.PrivateKey) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.private = private
+}
+
+// Close is a no-op
+func (b *KeyBroker) Close() {
+	// no-op
+}
+
+// Sign will sign the claims and return the token.
+func (b *KeyBroker) Sign(claims jwt.Claims) (string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(b.private)
+}
+
+// MustSign will sign the claims and return the token and will panic on failure.
+func (b *KeyBroker) MustSign(claims jwt.Claims) string {
+	token, err := b.Sign(claims)
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
+
+// Token will sign a new set of claims for the consumer and will panic on failure.
+func (b *KeyBroker) Token(consumer lushauth.Consumer) string {
+	claims, err := lushauth.NewClaimsForConsumer(Issuer, consumer)
+	if err != nil {
+		panic(err)
+	}
+	return b.MustSign(&claims)
+}
+
+// AuthorizationHeader returns the value of an authorization header for the consumer.
+func (b *KeyBroker) AuthorizationHeader(consumer lushauth.Consumer) string {
+	return "Bearer " + b.Token(consumer)
+}
+
+// Authorize sets the authorization header of the request for the consumer.
+func (b *KeyBroker) Authorize(r *http.Request, consumer lushauth.Consumer) *http.Request {
+	r.Header.Set("Authorization", b.AuthorizationHeader(consumer))
+	return r
+}
+
+// OutgoingContext adds a token for the consumer to the outgoing gRPC metadata of the context.
+// Use it for calls made by a gRPC client, such as one dialled over bufconn.
+func (b *KeyBroker) OutgoingContext(ctx context.Context, consumer lushauth.Consumer) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, metaAuthTokenKey, b.Token(consumer))
+}
+
+// IncomingContext adds a token for the consumer to the incoming gRPC metadata of the context.
+// Use it when calling a gRPC server interceptor or handler directly.
+func (b *KeyBroker) IncomingContext(ctx context.Context, consumer lushauth.Consumer) context.Context {
+	out, _ := metadata.FromOutgoingContext(b.OutgoingContext(context.Background(), consumer))
+	in, _ := metadata.FromIncomingContext(ctx)
+	return metadata.NewIncomingContext(ctx, metadata.Join(in, out))
+}
@@ -0,0 +1,52 @@
+package lushauthtest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core-lush/lushauth/lushauthtest"
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/LUSHDigital/core/auth/authmock"
+	"github.com/LUSHDigital/core/test"
+)
+
+var broker = lushauthtest.MustNewKeyBroker()
+
+func ExampleKeyBroker_Authorize() {
+	consumer := lushauthtest.NewConsumer().WithRoles("admin").Build()
+	handler := lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {})
+	r := broker.Authorize(httptest.NewRequest(http.MethodGet, "/", nil), consumer)
+	handler(httptest.NewRecorder(), r)
+}
+
+func TestKeyBroker_Authorize(t *testing.T) {
+	consumer := lushauthtest.NewConsumer().WithRoles("admin").Build()
+	var actual lushauth.Consumer
+	handler := lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {
+		actual = lushauth.ConsumerFromContext(r.Context())
+	})
+	recorder := httptest.NewRecorder()
+	handler(recorder, broker.Authorize(httptest.NewRequest(http.MethodGet, "/", nil), consumer))
+	test.Equals(t, http.StatusOK, recorder.Code)
+	test.Equals(t, consumer, actual)
+}
+
+func TestKeyBroker_IncomingContext(t *testing.T) {
+	consumer := lushauthtest.NewConsumer().WithMarket("gb", "digital_manager").Build()
+	ctx := broker.IncomingContext(context.Background(), consumer)
+	actual, err := lushauthmw.InterceptServerJWT(ctx, broker)
+	test.Equals(t, nil, err)
+	test.Equals(t, consumer, actual)
+}
+
+func TestKeyBroker_Rotate(t *testing.T) {
+	b := lushauthtest.MustNewKeyBroker()
+	ctx := b.IncomingContext(context.Background(), lushauthtest.NewConsumer().Build())
+	private, _ := authmock.MustNewRSAKeyPair()
+	b.Rotate(private)
+	_, err := lushauthmw.InterceptServerJWT(ctx, b)
+	test.NotEquals(t, nil, err)
+}
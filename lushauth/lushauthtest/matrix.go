@@ -0,0 +1,154 @@
+package lushauthtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+)
+
+const (
+	// MaxDimensions is the maximum number of roles, grants and markets that can be combined into consumers.
+	MaxDimensions = 16
+
+	// UpdateSnapshotsEnv is the environment variable which makes AssertSnapshot write snapshots rather than compare them.
+	UpdateSnapshotsEnv = "LUSHAUTHTEST_UPDATE"
+)
+
+// Dimensions describes the roles, grants and markets to combine into consumers.
+type Dimensions struct {
+	Roles   []string
+	Grants  []string
+	Markets []lushauth.Market
+}
+
+// Consumers generates a consumer for every combination of the roles, grants and markets.
+// It will panic when there are more than MaxDimensions roles, grants and markets in total.
+func (d Dimensions) Consumers() []lushauth.Consumer {
+	n := len(d.Roles) + len(d.Grants) + len(d.Markets)
+	if n > MaxDimensions {
+		panic(fmt.Sprintf("lushauthtest: %d dimensions exceeds the maximum of %d", n, MaxDimensions))
+	}
+	consumers := make([]lushauth.Consumer, 0, 1<<uint(n))
+	for mask := 0; mask < 1<<uint(n); mask++ {
+		c := lushauth.Consumer{
+			Roles:   []string{},
+			Grants:  []string{},
+			Markets: []lushauth.Market{},
+		}
+		bit := 0
+		for _, role := range d.Roles {
+			if mask&(1<<uint(bit)) != 0 {
+				c.Roles = append(c.Roles, role)
+			}
+			bit++
+		}
+		for _, grant := range d.Grants {
+			if mask&(1<<uint(bit)) != 0 {
+				c.Grants = append(c.Grants, grant)
+			}
+			bit++
+		}
+		for _, market := range d.Markets {
+			if mask&(1<<uint(bit)) != 0 {
+				c.Markets = append(c.Markets, market)
+			}
+			bit++
+		}
+		consumers = append(consumers, c)
+	}
+	return consumers
+}
+
+// Outcome is the result of evaluating a policy against a consumer.
+type Outcome struct {
+	Consumer  lushauth.Consumer
+	Permitted bool
+	Err       error
+}
+
+// String describes the outcome and the consumer it was evaluated against.
+func (o Outcome) String() string {
+	outcome := "deny  "
+	if o.Permitted {
+		outcome = "permit"
+	}
+	markets := make([]string, len(o.Consumer.Markets))
+	for i, m := range o.Consumer.Markets {
+		markets[i] = fmt.Sprintf("%s:%s", m.ID, strings.Join(append(append([]string{}, m.Roles...), m.Grants...), "+"))
+	}
+	return fmt.Sprintf("%s roles=[%s] grants=[%s] markets=[%s]",
+		outcome,
+		strings.Join(o.Consumer.Roles, " "),
+		strings.Join(o.Consumer.Grants, " "),
+		strings.Join(markets, " "),
+	)
+}
+
+// Matrix holds the outcomes of evaluating a policy against a set of consumers.
+type Matrix []Outcome
+
+// Evaluate evaluates a policy against every consumer.
+func Evaluate(p lushauth.Permitter, consumers ...lushauth.Consumer) Matrix {
+	m := make(Matrix, len(consumers))
+	for i, c := range consumers {
+		err := p.Permit(c)
+		m[i] = Outcome{Consumer: c, Permitted: err == nil, Err: err}
+	}
+	return m
+}
+
+// String renders the full allow and deny matrix with one consumer per line.
+func (m Matrix) String() string {
+	var sb strings.Builder
+	for _, o := range m {
+		sb.WriteString(o.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Permitted returns the consumers permitted by the policy.
+func (m Matrix) Permitted() []lushauth.Consumer {
+	var consumers []lushauth.Consumer
+	for _, o := range m {
+		if o.Permitted {
+			consumers = append(consumers, o.Consumer)
+		}
+	}
+	return consumers
+}
+
+// Denied returns the consumers denied by the policy.
+func (m Matrix) Denied() []lushauth.Consumer {
+	var consumers []lushauth.Consumer
+	for _, o := range m {
+		if !o.Permitted {
+			consumers = append(consumers, o.Consumer)
+		}
+	}
+	return consumers
+}
+
+// AssertSnapshot compares a matrix against a snapshot file and fails the test if they differ.
+// The snapshot is written instead when the UpdateSnapshotsEnv environment variable is set.
+func AssertSnapshot(tb testing.TB, path string, m Matrix) {
+	tb.Helper()
+	actual := m.String()
+	if os.Getenv(UpdateSnapshotsEnv) != "" {
+		if err := ioutil.WriteFile(path, []byte(actual), 0644); err != nil {
+			tb.Fatalf("could not write snapshot: %v", err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("could not read snapshot (set %s=1 to create it): %v", UpdateSnapshotsEnv, err)
+	}
+	if string(expected) != actual {
+		tb.Fatalf("access matrix does not match snapshot %s\n\texpected:\n%s\n\t  actual:\n%s", path, expected, actual)
+	}
+}
@@ -0,0 +1,50 @@
+package lushauthtest_test
+
+import (
+	"log"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core-lush/lushauth/lushauthtest"
+	"github.com/LUSHDigital/core/test"
+)
+
+var dimensions = lushauthtest.Dimensions{
+	Roles:  []string{"staff", "admin"},
+	Grants: []string{"pages.update"},
+	Markets: []lushauth.Market{
+		{ID: "gb", Roles: []string{"digital_manager"}},
+	},
+}
+
+func ExampleEvaluate() {
+	policy := lushauth.AnyPolicy{
+		lushauth.MarketPolicy{ID: "gb", Roles: []string{"digital_manager"}},
+		lushauth.RolePolicy{"admin"},
+	}
+	matrix := lushauthtest.Evaluate(policy, dimensions.Consumers()...)
+	log.Println(matrix)
+}
+
+func TestDimensions_Consumers(t *testing.T) {
+	consumers := dimensions.Consumers()
+	test.Equals(t, 16, len(consumers))
+	test.Equals(t, lushauth.Consumer{Roles: []string{}, Grants: []string{}, Markets: []lushauth.Market{}}, consumers[0])
+	test.Equals(t, []string{"staff", "admin"}, consumers[15].Roles)
+	test.Equals(t, []string{"pages.update"}, consumers[15].Grants)
+	test.Equals(t, dimensions.Markets, consumers[15].Markets)
+}
+
+func TestEvaluate(t *testing.T) {
+	policy := lushauth.AllPolicy{
+		lushauth.AnyPolicy{
+			lushauth.MarketPolicy{ID: "gb", Roles: []string{"digital_manager"}},
+			lushauth.RolePolicy{"admin"},
+		},
+		lushauth.GrantPolicy{"pages.update"},
+	}
+	matrix := lushauthtest.Evaluate(policy, dimensions.Consumers()...)
+	test.Equals(t, 6, len(matrix.Permitted()))
+	test.Equals(t, 10, len(matrix.Denied()))
+	lushauthtest.AssertSnapshot(t, "testdata/update_pages.txt", matrix)
+}
@@ -0,0 +1,570 @@
+package lushauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicySyntaxError happens when a policy definition can not be parsed.
+type PolicySyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e PolicySyntaxError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("policy:%d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("policy:%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// position is a line and column in a policy definition.
+type position struct {
+	line   int
+	column int
+}
+
+func (p position) errorf(format string, args ...interface{}) error {
+	return PolicySyntaxError{
+		Line:    p.line,
+		Column:  p.column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// policyNode is a parsed but not yet compiled policy.
+type policyNode struct {
+	pos  position
+	name string
+	args []policyArg
+}
+
+// policyArg is either a value or a nested policy.
+type policyArg struct {
+	pos   position
+	value string
+	node  *policyNode
+}
+
+// ParsePolicy parses a policy from its text representation.
+//
+//	any(
+//	    market("gb", "digital_manager"),
+//	    all(role("admin"), not(grant("users.delete"))),
+//	)
+//
+// The available policies are user, role, grant, market, market_grant, any, all and not.
+// Lines starting with # are ignored.
+func ParsePolicy(src []byte) (Permitter, error) {
+	p := &policyParser{lex: &policyLexer{src: src, line: 1, column: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	n, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.tok.pos.errorf("unexpected %s after policy", p.tok)
+	}
+	return compilePolicy(n)
+}
+
+// ParsePolicyJSON parses a policy from its JSON representation.
+//
+//	{"any": [
+//	    {"market": {"id": "gb", "roles": ["digital_manager"]}},
+//	    {"all": [{"role": ["admin"]}, {"not": {"grant": ["users.delete"]}}]}
+//	]}
+func ParsePolicyJSON(src []byte) (Permitter, error) {
+	var v interface{}
+	if err := json.Unmarshal(src, &v); err != nil {
+		if serr, ok := err.(*json.SyntaxError); ok {
+			return nil, offsetPosition(src, serr.Offset-1).errorf("%s", serr.Error())
+		}
+		return nil, err
+	}
+	return ParsePolicyYAML(src)
+}
+
+// ParsePolicyYAML parses a policy from its YAML representation.
+// Anchors can be defined but aliases to them are refused.
+//
+//	any:
+//	  - market:
+//	      id: gb
+//	      roles: [digital_manager]
+//	  - all:
+//	      - role: [admin]
+//	      - not:
+//	          grant: [users.delete]
+func ParsePolicyYAML(src []byte) (Permitter, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		var line int
+		msg := strings.TrimPrefix(err.Error(), "yaml: ")
+		if _, serr := fmt.Sscanf(msg, "line %d:", &line); serr == nil {
+			msg = strings.TrimPrefix(msg, fmt.Sprintf("line %d: ", line))
+		}
+		return nil, PolicySyntaxError{Line: line, Message: msg}
+	}
+	if len(doc.Content) == 0 {
+		return nil, position{1, 1}.errorf("missing policy")
+	}
+	n, err := yamlPolicyNode(doc.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	return compilePolicy(n)
+}
+
+// yamlAliasError happens for aliases, which are not followed since they can refer to themselves or expand exponentially.
+func yamlAliasError(y *yaml.Node) error {
+	return position{y.Line, y.Column}.errorf("aliases are not supported")
+}
+
+// yamlNull checks if a YAML node is a null scalar, such as null, ~ or an empty value.
+func yamlNull(y *yaml.Node) bool {
+	return y.Kind == yaml.ScalarNode && y.ShortTag() == "!!null"
+}
+
+// yamlValue returns the value of a YAML scalar for a policy, where null scalars have an empty value.
+// Scalars that are not strings, such as numbers and booleans, are refused.
+func yamlValue(name string, y *yaml.Node) (string, error) {
+	if yamlNull(y) {
+		return "", nil
+	}
+	if tag := y.ShortTag(); tag != "!!str" {
+		return "", position{y.Line, y.Column}.errorf("%s policy needs string values, found %s", name, strings.TrimPrefix(tag, "!!"))
+	}
+	return y.Value, nil
+}
+
+// yamlPolicyNode converts a YAML node into a policy node.
+func yamlPolicyNode(y *yaml.Node) (*policyNode, error) {
+	if y.Kind == yaml.AliasNode {
+		return nil, yamlAliasError(y)
+	}
+	pos := position{y.Line, y.Column}
+	if y.Kind != yaml.MappingNode || len(y.Content) != 2 {
+		return nil, pos.errorf("expected a policy with a single key")
+	}
+	key, value := y.Content[0], y.Content[1]
+	if value.Kind == yaml.AliasNode {
+		return nil, yamlAliasError(value)
+	}
+	n := &policyNode{pos: position{key.Line, key.Column}, name: key.Value}
+	switch n.name {
+	case "market", "market_grant":
+		return n, yamlMarketArgs(n, value)
+	}
+	switch {
+	case yamlNull(value):
+		// A null value leaves the policy without arguments, which fails to compile.
+	case value.Kind == yaml.ScalarNode:
+		v, err := yamlValue(n.name, value)
+		if err != nil {
+			return nil, err
+		}
+		n.args = append(n.args, policyArg{pos: position{value.Line, value.Column}, value: v})
+	case value.Kind == yaml.MappingNode:
+		child, err := yamlPolicyNode(value)
+		if err != nil {
+			return nil, err
+		}
+		n.args = append(n.args, policyArg{pos: child.pos, node: child})
+	case value.Kind == yaml.SequenceNode:
+		for _, item := range value.Content {
+			if item.Kind == yaml.ScalarNode {
+				v, err := yamlValue(n.name, item)
+				if err != nil {
+					return nil, err
+				}
+				n.args = append(n.args, policyArg{pos: position{item.Line, item.Column}, value: v})
+				continue
+			}
+			child, err := yamlPolicyNode(item)
+			if err != nil {
+				return nil, err
+			}
+			n.args = append(n.args, policyArg{pos: child.pos, node: child})
+		}
+	default:
+		return nil, position{value.Line, value.Column}.errorf("unexpected value for %s policy", n.name)
+	}
+	return n, nil
+}
+
+// yamlMarketArgs converts a market mapping with an id and its roles or grants into policy arguments.
+func yamlMarketArgs(n *policyNode, value *yaml.Node) error {
+	field := "roles"
+	if n.name == "market_grant" {
+		field = "grants"
+	}
+	pos := position{value.Line, value.Column}
+	if value.Kind != yaml.MappingNode {
+		return pos.errorf("%s policy needs a mapping with an id and %s", n.name, field)
+	}
+	var id *yaml.Node
+	var values []*yaml.Node
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		k, v := value.Content[i], value.Content[i+1]
+		if v.Kind == yaml.AliasNode {
+			return yamlAliasError(v)
+		}
+		switch k.Value {
+		case "id":
+			if yamlNull(v) {
+				continue
+			}
+			if v.Kind != yaml.ScalarNode {
+				return position{v.Line, v.Column}.errorf("%s policy needs a market id", n.name)
+			}
+			id = v
+		case field:
+			if yamlNull(v) {
+				continue
+			}
+			switch v.Kind {
+			case yaml.ScalarNode:
+				values = append(values, v)
+			case yaml.SequenceNode:
+				values = append(values, v.Content...)
+			default:
+				return position{v.Line, v.Column}.errorf("%s policy needs a list of %s", n.name, field)
+			}
+		default:
+			return position{k.Line, k.Column}.errorf("unknown field %q for %s policy", k.Value, n.name)
+		}
+	}
+	if id == nil {
+		return pos.errorf("%s policy needs a market id", n.name)
+	}
+	idValue, err := yamlValue(n.name, id)
+	if err != nil {
+		return err
+	}
+	n.args = append(n.args, policyArg{pos: position{id.Line, id.Column}, value: idValue})
+	for _, v := range values {
+		if v.Kind == yaml.AliasNode {
+			return yamlAliasError(v)
+		}
+		if v.Kind != yaml.ScalarNode {
+			return position{v.Line, v.Column}.errorf("%s policy needs a list of %s", n.name, field)
+		}
+		value, err := yamlValue(n.name, v)
+		if err != nil {
+			return err
+		}
+		n.args = append(n.args, policyArg{pos: position{v.Line, v.Column}, value: value})
+	}
+	return nil
+}
+
+// offsetPosition finds the line and column of a byte offset.
+func offsetPosition(src []byte, offset int64) position {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(len(src)) {
+		offset = int64(len(src))
+	}
+	before := src[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return position{line, column}
+}
+
+// compilePolicy validates a policy node and compiles it into a permitter.
+func compilePolicy(n *policyNode) (Permitter, error) {
+	switch n.name {
+	case "user":
+		values, err := n.values("user")
+		if err != nil {
+			return nil, err
+		}
+		return UserPolicy(values), nil
+	case "role":
+		values, err := n.values("role")
+		if err != nil {
+			return nil, err
+		}
+		return RolePolicy(values), nil
+	case "grant":
+		values, err := n.values("grant")
+		if err != nil {
+			return nil, err
+		}
+		return GrantPolicy(values), nil
+	case "market":
+		id, values, err := n.market("role")
+		if err != nil {
+			return nil, err
+		}
+		return MarketPolicy{ID: id, Roles: values}, nil
+	case "market_grant":
+		id, values, err := n.market("grant")
+		if err != nil {
+			return nil, err
+		}
+		return MarketGrantPolicy{ID: id, Grants: values}, nil
+	case "any":
+		policies, err := n.policies()
+		if err != nil {
+			return nil, err
+		}
+		return AnyPolicy(policies), nil
+	case "all":
+		policies, err := n.policies()
+		if err != nil {
+			return nil, err
+		}
+		return AllPolicy(policies), nil
+	case "not":
+		policies, err := n.policies()
+		if err != nil {
+			return nil, err
+		}
+		if len(policies) > 1 {
+			return nil, n.args[1].pos.errorf("not policy takes exactly one policy")
+		}
+		return NotPolicy{Policy: policies[0]}, nil
+	}
+	return nil, n.pos.errorf("unknown policy %q", n.name)
+}
+
+// values returns the arguments of a node which only takes values.
+func (n *policyNode) values(kind string) ([]string, error) {
+	if len(n.args) == 0 {
+		return nil, n.pos.errorf("%s policy needs at least one %s", n.name, kind)
+	}
+	values := make([]string, len(n.args))
+	for i, arg := range n.args {
+		if arg.node != nil {
+			return nil, arg.pos.errorf("%s policy takes values, not policies", n.name)
+		}
+		if arg.value == "" {
+			return nil, arg.pos.errorf("%s policy can not have an empty %s", n.name, kind)
+		}
+		values[i] = arg.value
+	}
+	return values, nil
+}
+
+// market returns the market id and the values of a node for a market policy.
+func (n *policyNode) market(kind string) (string, []string, error) {
+	if len(n.args) == 0 {
+		return "", nil, n.pos.errorf("%s policy needs a market id", n.name)
+	}
+	if n.args[0].node != nil || n.args[0].value == "" {
+		return "", nil, n.args[0].pos.errorf("%s policy needs a market id", n.name)
+	}
+	rest := &policyNode{pos: n.pos, name: n.name, args: n.args[1:]}
+	values, err := rest.values(kind)
+	return n.args[0].value, values, err
+}
+
+// policies compiles the arguments of a node which only takes policies.
+func (n *policyNode) policies() ([]Permitter, error) {
+	if len(n.args) == 0 {
+		return nil, n.pos.errorf("%s policy needs at least one policy", n.name)
+	}
+	policies := make([]Permitter, len(n.args))
+	for i, arg := range n.args {
+		if arg.node == nil {
+			return nil, arg.pos.errorf("%s policy takes policies, not values", n.name)
+		}
+		policy, err := compilePolicy(arg.node)
+		if err != nil {
+			return nil, err
+		}
+		policies[i] = policy
+	}
+	return policies, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+// policyToken is a lexical token of the policy text format.
+type policyToken struct {
+	kind  tokenKind
+	pos   position
+	value string
+}
+
+func (t policyToken) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of policy"
+	case tokenIdent:
+		return fmt.Sprintf("policy %q", t.value)
+	case tokenString:
+		return fmt.Sprintf("value %q", t.value)
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// policyLexer splits the policy text format into tokens.
+type policyLexer struct {
+	src    []byte
+	off    int
+	line   int
+	column int
+}
+
+func (l *policyLexer) pos() position {
+	return position{l.line, l.column}
+}
+
+func (l *policyLexer) read() byte {
+	c := l.src[l.off]
+	l.off++
+	if c == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return c
+}
+
+func (l *policyLexer) next() (policyToken, error) {
+	for l.off < len(l.src) {
+		switch c := l.src[l.off]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			l.read()
+		case c == '#':
+			for l.off < len(l.src) && l.src[l.off] != '\n' {
+				l.read()
+			}
+		default:
+			return l.token()
+		}
+	}
+	return policyToken{kind: tokenEOF, pos: l.pos()}, nil
+}
+
+func (l *policyLexer) token() (policyToken, error) {
+	pos := l.pos()
+	switch c := l.src[l.off]; {
+	case c == '(':
+		l.read()
+		return policyToken{kind: tokenLParen, pos: pos, value: "("}, nil
+	case c == ')':
+		l.read()
+		return policyToken{kind: tokenRParen, pos: pos, value: ")"}, nil
+	case c == ',':
+		l.read()
+		return policyToken{kind: tokenComma, pos: pos, value: ","}, nil
+	case c == '"':
+		return l.string()
+	case isIdentByte(c):
+		start := l.off
+		for l.off < len(l.src) && isIdentByte(l.src[l.off]) {
+			l.read()
+		}
+		return policyToken{kind: tokenIdent, pos: pos, value: string(l.src[start:l.off])}, nil
+	default:
+		return policyToken{}, pos.errorf("unexpected character %q", c)
+	}
+}
+
+func (l *policyLexer) string() (policyToken, error) {
+	pos := l.pos()
+	start := l.off
+	l.read()
+	for l.off < len(l.src) {
+		switch l.read() {
+		case '\\':
+			if l.off < len(l.src) {
+				l.read()
+			}
+		case '\n':
+			return policyToken{}, pos.errorf("unterminated value")
+		case '"':
+			value, err := strconv.Unquote(string(l.src[start:l.off]))
+			if err != nil {
+				return policyToken{}, pos.errorf("invalid value %s", l.src[start:l.off])
+			}
+			return policyToken{kind: tokenString, pos: pos, value: value}, nil
+		}
+	}
+	return policyToken{}, pos.errorf("unterminated value")
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// policyParser parses the policy text format into policy nodes.
+type policyParser struct {
+	lex *policyLexer
+	tok policyToken
+}
+
+func (p *policyParser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *policyParser) parse() (*policyNode, error) {
+	if p.tok.kind != tokenIdent {
+		return nil, p.tok.pos.errorf("expected a policy, found %s", p.tok)
+	}
+	n := &policyNode{pos: p.tok.pos, name: p.tok.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenLParen {
+		return nil, p.tok.pos.errorf("expected \"(\" after %s policy, found %s", n.name, p.tok)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for p.tok.kind != tokenRParen {
+		switch p.tok.kind {
+		case tokenString:
+			n.args = append(n.args, policyArg{pos: p.tok.pos, value: p.tok.value})
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		case tokenIdent:
+			child, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			n.args = append(n.args, policyArg{pos: child.pos, node: child})
+		default:
+			return nil, p.tok.pos.errorf("expected a value or a policy, found %s", p.tok)
+		}
+		switch p.tok.kind {
+		case tokenComma:
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		case tokenRParen:
+		default:
+			return nil, p.tok.pos.errorf("expected \",\" or \")\", found %s", p.tok)
+		}
+	}
+	return n, p.advance()
+}
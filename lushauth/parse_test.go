@@ -0,0 +1,298 @@
+package lushauth_test
+
+import (
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+)
+
+var parsedPolicy = lushauth.AnyPolicy{
+	lushauth.MarketPolicy{ID: "gb", Roles: []string{"digital_manager"}},
+	lushauth.AllPolicy{
+		lushauth.RolePolicy{"admin"},
+		lushauth.NotPolicy{Policy: lushauth.GrantPolicy{"users.delete"}},
+	},
+	lushauth.MarketGrantPolicy{ID: "de", Grants: []string{"tills.read"}},
+	lushauth.UserPolicy{"5d4b32f9-5954-41c3-a470-7d76317635a7"},
+}
+
+func ExampleParsePolicy() {
+	policy, err := lushauth.ParsePolicy([]byte(`any(role("admin"), grant("users.delete"))`))
+	if err != nil {
+		return
+	}
+	policy.Permit(consumer)
+}
+
+func TestParsePolicy(t *testing.T) {
+	cases := []struct {
+		name        string
+		src         string
+		expected    lushauth.Permitter
+		expectedErr error
+	}{
+		{
+			name: "valid policy",
+			src: `# Digital managers or admins who can not delete users.
+any(
+	market("gb", "digital_manager"),
+	all(role("admin"), not(grant("users.delete"))),
+	market_grant("de", "tills.read"),
+	user("5d4b32f9-5954-41c3-a470-7d76317635a7"),
+)`,
+			expected: parsedPolicy,
+		},
+		{
+			name:        "unknown policy",
+			src:         "any(\n\trole(\"admin\"),\n\tgroup(\"staff\"))",
+			expectedErr: lushauth.PolicySyntaxError{Line: 3, Column: 2, Message: `unknown policy "group"`},
+		},
+		{
+			name:        "missing closing parenthesis",
+			src:         `all(role("admin")`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 18, Message: `expected "," or ")", found end of policy`},
+		},
+		{
+			name:        "unterminated value",
+			src:         `role("admin)`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 6, Message: `unterminated value`},
+		},
+		{
+			name:        "policy without values",
+			src:         `role()`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 1, Message: `role policy needs at least one role`},
+		},
+		{
+			name:        "values given to a composite policy",
+			src:         `any(role("admin"), "staff")`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 20, Message: `any policy takes policies, not values`},
+		},
+		{
+			name:        "policies given to a value policy",
+			src:         `role(grant("users.read"))`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 6, Message: `role policy takes values, not policies`},
+		},
+		{
+			name:        "negating more than one policy",
+			src:         `not(role("admin"), role("staff"))`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 20, Message: `not policy takes exactly one policy`},
+		},
+		{
+			name:        "market without roles",
+			src:         `market("gb")`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 1, Message: `market policy needs at least one role`},
+		},
+		{
+			name:        "trailing input",
+			src:         `role("admin") role("staff")`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 15, Message: `unexpected policy "role" after policy`},
+		},
+		{
+			name:        "unexpected character",
+			src:         `role('admin')`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 6, Message: `unexpected character '\''`},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			policy, err := lushauth.ParsePolicy([]byte(c.src))
+			test.Equals(t, c.expectedErr, err)
+			test.Equals(t, c.expected, policy)
+		})
+	}
+}
+
+func TestParsePolicyJSON(t *testing.T) {
+	cases := []struct {
+		name        string
+		src         string
+		expected    lushauth.Permitter
+		expectedErr error
+	}{
+		{
+			name: "valid policy",
+			src: `{"any": [
+	{"market": {"id": "gb", "roles": ["digital_manager"]}},
+	{"all": [{"role": ["admin"]}, {"not": {"grant": ["users.delete"]}}]},
+	{"market_grant": {"id": "de", "grants": ["tills.read"]}},
+	{"user": "5d4b32f9-5954-41c3-a470-7d76317635a7"}
+]}`,
+			expected: parsedPolicy,
+		},
+		{
+			name:        "invalid json",
+			src:         "{\"any\": [\n\t{\"role\": [\"admin\"]},\n]}",
+			expectedErr: lushauth.PolicySyntaxError{Line: 3, Column: 1, Message: `invalid character ']' looking for beginning of value`},
+		},
+		{
+			name:        "unknown market field",
+			src:         "{\"market\": {\"id\": \"gb\", \"role\": [\"staff\"]}}",
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 25, Message: `unknown field "role" for market policy`},
+		},
+		{
+			name:        "null role",
+			src:         `{"role": null}`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 2, Message: `role policy needs at least one role`},
+		},
+		{
+			name:        "number role",
+			src:         `{"role": [1]}`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 11, Message: `role policy needs string values, found int`},
+		},
+		{
+			name:        "boolean grant",
+			src:         `{"grant": true}`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 11, Message: `grant policy needs string values, found bool`},
+		},
+		{
+			name:        "number market id",
+			src:         `{"market": {"id": 44, "roles": ["staff"]}}`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 19, Message: `market policy needs string values, found int`},
+		},
+		{
+			name:        "policy with more than one key",
+			src:         `{"any": [{"role": ["admin"], "grant": ["users.read"]}]}`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 10, Message: `expected a policy with a single key`},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			policy, err := lushauth.ParsePolicyJSON([]byte(c.src))
+			test.Equals(t, c.expectedErr, err)
+			test.Equals(t, c.expected, policy)
+		})
+	}
+}
+
+func TestParsePolicyYAML(t *testing.T) {
+	cases := []struct {
+		name        string
+		src         string
+		expected    lushauth.Permitter
+		expectedErr error
+	}{
+		{
+			name: "valid policy",
+			src: `any:
+  - market:
+      id: gb
+      roles: [digital_manager]
+  - all:
+      - role: admin
+      - not:
+          grant: [users.delete]
+  - market_grant:
+      id: de
+      grants: tills.read
+  - user: [5d4b32f9-5954-41c3-a470-7d76317635a7]
+`,
+			expected: parsedPolicy,
+		},
+		{
+			name: "market without id",
+			src: `all:
+  - market:
+      roles: [staff]
+`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 3, Column: 7, Message: `market policy needs a market id`},
+		},
+		{
+			name:        "invalid yaml",
+			src:         "any:\n  - role: [admin\n",
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Message: `did not find expected ',' or ']'`},
+		},
+		{
+			name:        "null role",
+			src:         "role: ~\n",
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 1, Message: `role policy needs at least one role`},
+		},
+		{
+			name:        "null grant in not policy",
+			src:         "not:\n  grant: ~\n",
+			expectedErr: lushauth.PolicySyntaxError{Line: 2, Column: 3, Message: `grant policy needs at least one grant`},
+		},
+		{
+			name:        "null not policy",
+			src:         "not: null\n",
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 1, Message: `not policy needs at least one policy`},
+		},
+		{
+			name:        "empty role",
+			src:         "role:\n",
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 1, Message: `role policy needs at least one role`},
+		},
+		{
+			name:        "null role in a list",
+			src:         "role: [admin, null]\n",
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 15, Message: `role policy can not have an empty role`},
+		},
+		{
+			name:        "number role in a list",
+			src:         "role: [admin, 1.5]\n",
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 15, Message: `role policy needs string values, found float`},
+		},
+		{
+			name:     "quoted number role",
+			src:      "role: \"1\"\n",
+			expected: lushauth.RolePolicy{"1"},
+		},
+		{
+			name:        "empty string role",
+			src:         "role: \"\"\n",
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 7, Message: `role policy can not have an empty role`},
+		},
+		{
+			name: "null market roles",
+			src: `market:
+  id: gb
+  roles: ~
+`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 1, Message: `market policy needs at least one role`},
+		},
+		{
+			name: "null market id",
+			src: `market:
+  id: null
+  roles: [staff]
+`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 2, Column: 3, Message: `market policy needs a market id`},
+		},
+		{
+			name:        "self referencing alias",
+			src:         "&a\nany:\n  - *a\n",
+			expectedErr: lushauth.PolicySyntaxError{Line: 3, Column: 5, Message: `aliases are not supported`},
+		},
+		{
+			name: "alias to another policy",
+			src: `all:
+  - &admin
+    role: admin
+  - not: *admin
+`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 4, Column: 10, Message: `aliases are not supported`},
+		},
+		{
+			name: "alias in market roles",
+			src: `any:
+  - role: &roles [staff]
+  - market:
+      id: gb
+      roles: *roles
+`,
+			expectedErr: lushauth.PolicySyntaxError{Line: 5, Column: 14, Message: `aliases are not supported`},
+		},
+		{
+			name:        "empty document",
+			src:         "",
+			expectedErr: lushauth.PolicySyntaxError{Line: 1, Column: 1, Message: `missing policy`},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			policy, err := lushauth.ParsePolicyYAML([]byte(c.src))
+			test.Equals(t, c.expectedErr, err)
+			test.Equals(t, c.expected, policy)
+		})
+	}
+}
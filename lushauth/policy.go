@@ -55,6 +55,28 @@ func (p GrantPolicy) Error() string {
 	return fmt.Sprintf("need to have any of the %s grants", strings.Join(quote(p...), ", "))
 }
 
+// Compile precompiles the grants of the policy for repeated use.
+func (p GrantPolicy) Compile() CompiledGrantPolicy {
+	return CompiledGrantPolicy{
+		GrantPolicy: p,
+		matcher:     NewGrantMatcher(p...),
+	}
+}
+
+// CompiledGrantPolicy is a grant policy with its grants precompiled into a matcher.
+type CompiledGrantPolicy struct {
+	GrantPolicy
+	matcher *GrantMatcher
+}
+
+// Permit a consumer or return an error.
+func (p CompiledGrantPolicy) Permit(c Consumer) error {
+	if !p.matcher.MatchAny(c.Grants...) {
+		return p.GrantPolicy
+	}
+	return nil
+}
+
 // MarketPolicy defines what roles to allow access for in a given market.
 type MarketPolicy struct {
 	ID    string
@@ -73,6 +95,24 @@ func (p MarketPolicy) Error() string {
 	return fmt.Sprintf("need to be a part of the %q market with any of the %s market roles", p.ID, strings.Join(quote(p.Roles...), ", "))
 }
 
+// MarketGrantPolicy defines what grants to allow access for in a given market.
+type MarketGrantPolicy struct {
+	ID     string
+	Grants []string
+}
+
+// Permit a consumer or return an error.
+func (p MarketGrantPolicy) Permit(c Consumer) error {
+	if !c.HasAnyMarketGrant(p.ID, p.Grants...) {
+		return p
+	}
+	return nil
+}
+
+func (p MarketGrantPolicy) Error() string {
+	return fmt.Sprintf("need to be a part of the %q market with any of the %s market grants", p.ID, strings.Join(quote(p.Grants...), ", "))
+}
+
 // AnyPolicy defines a policy made up of multiple other policies where any of them will permit access.
 type AnyPolicy []Permitter
 
@@ -105,6 +145,34 @@ func (p AllPolicy) Permit(c Consumer) error {
 	return nil
 }
 
+// NotPolicy defines a policy which permits access only when the wrapped policy does not.
+type NotPolicy struct {
+	Policy Permitter
+}
+
+// Permit a consumer or return an error.
+func (p NotPolicy) Permit(c Consumer) error {
+	if err := p.Policy.Permit(c); err != nil {
+		return nil
+	}
+	return p
+}
+
+func (p NotPolicy) Error() string {
+	return fmt.Sprintf("need to not be permitted by the negated policy")
+}
+
+// CataloguePolicy defines a policy which is evaluated against the effective roles and grants of a consumer.
+type CataloguePolicy struct {
+	Catalogue RoleCatalogue
+	Policy    Permitter
+}
+
+// Permit a consumer or return an error.
+func (p CataloguePolicy) Permit(c Consumer) error {
+	return p.Policy.Permit(p.Catalogue.Expand(c))
+}
+
 // quote will take a slice of strings and quote each of them
 func quote(unquoted ...string) []string {
 	quoted := make([]string, len(unquoted))
@@ -135,6 +135,54 @@ func TestGrantPolicy_Permit(t *testing.T) {
 			policy:   DeleteUsersPolicy,
 			expected: DeleteUsersPolicy,
 		},
+		{
+			name:     "delete users policy with consumer having a wildcard grant",
+			consumer: lushauth.Consumer{Grants: []string{"users.*"}},
+			policy:   DeleteUsersPolicy,
+			expected: nil,
+		},
+		{
+			name:     "compiled delete users policy with permitted consumer",
+			consumer: Deleter,
+			policy:   DeleteUsersPolicy.Compile(),
+			expected: nil,
+		},
+		{
+			name:     "compiled delete users policy with consumer having a wildcard grant",
+			consumer: lushauth.Consumer{Grants: []string{"*.delete"}},
+			policy:   DeleteUsersPolicy.Compile(),
+			expected: nil,
+		},
+		{
+			name:     "compiled delete users policy with consumer lacking the grant",
+			consumer: Staff,
+			policy:   DeleteUsersPolicy.Compile(),
+			expected: DeleteUsersPolicy,
+		},
+		{
+			name:     "wildcard policy with consumer having a narrower grant",
+			consumer: lushauth.Consumer{Grants: []string{"products.read"}},
+			policy:   lushauth.GrantPolicy{"products.*"},
+			expected: lushauth.GrantPolicy{"products.*"},
+		},
+		{
+			name:     "compiled wildcard policy with consumer having a narrower grant",
+			consumer: lushauth.Consumer{Grants: []string{"products.read"}},
+			policy:   lushauth.GrantPolicy{"products.*"}.Compile(),
+			expected: lushauth.GrantPolicy{"products.*"},
+		},
+		{
+			name:     "policy for everything with consumer having any grant",
+			consumer: lushauth.Consumer{Grants: []string{"products.read"}},
+			policy:   lushauth.GrantPolicy{"*"},
+			expected: lushauth.GrantPolicy{"*"},
+		},
+		{
+			name:     "compiled policy for everything with consumer having any grant",
+			consumer: lushauth.Consumer{Grants: []string{"products.read"}},
+			policy:   lushauth.GrantPolicy{"*"}.Compile(),
+			expected: lushauth.GrantPolicy{"*"},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -235,6 +283,69 @@ func TestMarketPolicy_Permit(t *testing.T) {
 	}
 }
 
+func ExampleMarketGrantPolicy() {
+	policy := lushauth.MarketGrantPolicy{
+		ID: "gb",
+		Grants: []string{
+			"tills.close",
+		},
+	}
+	policy.Permit(consumer)
+}
+
+func TestMarketGrantPolicy_Permit(t *testing.T) {
+	var (
+		CloseTillsPolicy = lushauth.MarketGrantPolicy{
+			ID:     "gb",
+			Grants: []string{"tills.close"},
+		}
+		TillCloser = lushauth.Consumer{
+			UUID: PolicyUserID,
+			Markets: []lushauth.Market{
+				{
+					ID:     "gb",
+					Grants: []string{"tills.close"},
+				},
+				{
+					ID:     "de",
+					Grants: []string{"tills.read"},
+				},
+			},
+		}
+	)
+	type Test struct {
+		name     string
+		consumer lushauth.Consumer
+		policy   lushauth.Permitter
+		expected error
+	}
+	cases := []Test{
+		{
+			name:     "british market grant with permitted consumer",
+			consumer: TillCloser,
+			policy:   CloseTillsPolicy,
+			expected: nil,
+		},
+		{
+			name:     "german market grant with consumer lacking the grant",
+			consumer: TillCloser,
+			policy:   lushauth.MarketGrantPolicy{ID: "de", Grants: []string{"tills.close"}},
+			expected: lushauth.MarketGrantPolicy{ID: "de", Grants: []string{"tills.close"}},
+		},
+		{
+			name:     "british market grant with consumer not part of the market",
+			consumer: Guest,
+			policy:   CloseTillsPolicy,
+			expected: CloseTillsPolicy,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			AssertPermit(t, c.expected, c.policy, c.consumer)
+		})
+	}
+}
+
 func ExampleAnyPolicy() {
 	policy := lushauth.AnyPolicy{
 		lushauth.GrantPolicy{"users.delete"},
@@ -354,3 +465,43 @@ func AssertPermit(t *testing.T, expected error, p lushauth.Permitter, c lushauth
 	}
 	test.Equals(t, expected, err)
 }
+
+func ExampleNotPolicy() {
+	policy := lushauth.NotPolicy{
+		Policy: lushauth.RolePolicy{"guest"},
+	}
+	policy.Permit(consumer)
+}
+
+func TestNotPolicy_Permit(t *testing.T) {
+	var (
+		NotGuestPolicy = lushauth.NotPolicy{
+			Policy: lushauth.UserPolicy{GuestID},
+		}
+	)
+	type Test struct {
+		name     string
+		consumer lushauth.Consumer
+		policy   lushauth.Permitter
+		expected error
+	}
+	cases := []Test{
+		{
+			name:     "not guest policy with staff consumer",
+			consumer: Staff,
+			policy:   NotGuestPolicy,
+			expected: nil,
+		},
+		{
+			name:     "not guest policy with guest consumer",
+			consumer: Guest,
+			policy:   NotGuestPolicy,
+			expected: NotGuestPolicy,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			AssertPermit(t, c.expected, c.policy, c.consumer)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package lushauth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ConsumerLoader defines the behavior of loading an up to date snapshot of a consumer.
+type ConsumerLoader interface {
+	LoadConsumer(ctx context.Context, c Consumer) (Consumer, error)
+}
+
+// ConsumerLoaderFunc allows a function to be used as a ConsumerLoader.
+type ConsumerLoaderFunc func(ctx context.Context, c Consumer) (Consumer, error)
+
+// LoadConsumer calls the function with the given consumer.
+func (f ConsumerLoaderFunc) LoadConsumer(ctx context.Context, c Consumer) (Consumer, error) {
+	return f(ctx, c)
+}
+
+// JWTRefreshWindowError happens when a set of claims was issued too long ago to be refreshed.
+type JWTRefreshWindowError struct {
+	// IssuedAt is when the consumer originally authenticated.
+	IssuedAt int64
+	Window   time.Duration
+}
+
+func (e JWTRefreshWindowError) Error() string {
+	return fmt.Sprintf("token issued at %s can not be refreshed after %s", time.Unix(e.IssuedAt, 0).UTC().Format(time.RFC3339), e.Window)
+}
+
+// JWTRefreshActorError happens when a set of claims with an actor acting on behalf of the consumer is refreshed.
+// Claims with an actor have to be issued again instead, so that the actor is checked again and keeps a short lifetime.
+type JWTRefreshActorError struct {
+	Subject string
+}
+
+func (e JWTRefreshActorError) Error() string {
+	return fmt.Sprintf("token with %s acting on behalf of the consumer can not be refreshed", e.Subject)
+}
+
+// Refresher issues new claims in exchange for expired but otherwise valid claims.
+type Refresher struct {
+	// Issuer is used for the refreshed claims. The issuer of the refreshed claims is kept when empty.
+	Issuer string
+	// Window is the period after the consumer originally authenticated that a set of claims can be refreshed.
+	// Claims that have been refreshed before keep the time of the original authentication, so refreshing can not extend it.
+	Window time.Duration
+	// Loader provides the consumer for the refreshed claims. The consumer of the refreshed claims is kept when nil.
+	// It is not used for claims of a service.
+	Loader ConsumerLoader
+	// Revocation prevents revoked claims from being refreshed when set.
+	// When it is also a Revoker, claims are revoked once they have been refreshed so they can only be refreshed once.
+	Revocation RevocationChecker
+}
+
+// NewRefresher creates a refresher using the default refresh window.
+func NewRefresher(issuer string, loader ConsumerLoader) *Refresher {
+	return &Refresher{
+		Issuer: issuer,
+		Window: DefaultRefreshWindow,
+		Loader: loader,
+	}
+}
+
+// Refresh validates a set of refreshable claims and issues new claims with a new id and a fresh consumer.
+// Claims for a service are refreshed for the same service without loading a consumer,
+// while claims with an actor acting on behalf of the consumer are refused.
+func (r *Refresher) Refresh(ctx context.Context, c RefreshableClaims) (Claims, error) {
+	var claims Claims
+	if err := c.Valid(); err != nil {
+		return claims, err
+	}
+	if c.Actor != nil {
+		return claims, JWTRefreshActorError{Subject: c.Actor.Subject}
+	}
+	authTime := c.AuthTime
+	if authTime == 0 {
+		authTime = c.IssuedAt
+	}
+	windowEnd := time.Unix(authTime, 0).Add(r.Window)
+	if TimeFunc().After(windowEnd) {
+		return claims, JWTRefreshWindowError{IssuedAt: authTime, Window: r.Window}
+	}
+	if r.Revocation != nil {
+		if err := CheckRevocation(ctx, r.Revocation, c.Claims); err != nil {
+			return claims, err
+		}
+	}
+	issuer := r.Issuer
+	if issuer == "" {
+		issuer = c.Issuer
+	}
+	claims, err := r.refreshed(ctx, issuer, c.Claims)
+	if err != nil {
+		return claims, err
+	}
+	claims.Audience = c.Audience
+	claims.Subject = c.Subject
+	claims.ParentID = c.ID
+	claims.AuthTime = authTime
+	claims.Extra = c.Extra
+	if revoker, ok := r.Revocation.(Revoker); ok {
+		// Revoke the refreshed claims so they can not be refreshed again, nor used if they have not expired yet.
+		// Checking and revoking happens at once, so that only one of any concurrent refreshes of the same claims succeeds.
+		expiresAt := windowEnd.Unix()
+		if c.ExpiresAt > expiresAt {
+			expiresAt = c.ExpiresAt
+		}
+		if !revoker.RevokeIfAbsent(c.ID, expiresAt) {
+			return Claims{}, JWTRevokedError{c.ID}
+		}
+	}
+	return claims, nil
+}
+
+// refreshed spawns the new claims for the service or the fresh consumer of a set of claims.
+func (r *Refresher) refreshed(ctx context.Context, issuer string, c Claims) (Claims, error) {
+	if c.Service != nil {
+		return NewClaimsForService(issuer, *c.Service)
+	}
+	consumer := c.Consumer
+	if r.Loader != nil {
+		var err error
+		if consumer, err = r.Loader.LoadConsumer(ctx, c.Consumer); err != nil {
+			return Claims{}, err
+		}
+	}
+	return NewClaimsForConsumer(issuer, consumer)
+}
+
+// RefreshToken parses a raw token and refreshes its claims.
+func (r *Refresher) RefreshToken(ctx context.Context, raw string, keyfunc jwt.Keyfunc) (Claims, error) {
+	var c RefreshableClaims
+	if _, err := jwt.ParseWithClaims(raw, &c, keyfunc); err != nil {
+		return Claims{}, err
+	}
+	return r.Refresh(ctx, c)
+}
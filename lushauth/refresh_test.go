@@ -0,0 +1,212 @@
+package lushauth_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func ExampleRefresher() {
+	refresher := lushauth.NewRefresher("Test", lushauth.ConsumerLoaderFunc(func(ctx context.Context, c lushauth.Consumer) (lushauth.Consumer, error) {
+		return c, nil // Load the consumer from your user store here.
+	}))
+	refresher.Refresh(context.Background(), lushauth.RefreshableClaims{Claims: expiredClaims})
+}
+
+func TestRefresher_Refresh(t *testing.T) {
+	errLoad := errors.New("user not found")
+	loader := lushauth.ConsumerLoaderFunc(func(ctx context.Context, c lushauth.Consumer) (lushauth.Consumer, error) {
+		if c.UUID == "" {
+			return c, errLoad
+		}
+		c.Roles = []string{"staff"}
+		return c, nil
+	})
+	stale := expiredClaims
+	stale.IssuedAt = now.Add(-25 * time.Hour).Unix()
+	unknown := expiredClaims
+	unknown.Consumer = lushauth.Consumer{}
+	revokedClaims := expiredClaims
+	revokedClaims.ID = "revoked"
+	chained := expiredClaims
+	chained.AuthTime = now.Add(-25 * time.Hour).Unix()
+
+	type Test struct {
+		name        string
+		claims      lushauth.Claims
+		expectedErr error
+	}
+	cases := []Test{
+		{
+			name:   "expired claims within the refresh window",
+			claims: expiredClaims,
+		},
+		{
+			name:   "valid claims within the refresh window",
+			claims: validClaims,
+		},
+		{
+			name:   "claims outside the refresh window",
+			claims: stale,
+			expectedErr: lushauth.JWTRefreshWindowError{
+				IssuedAt: stale.IssuedAt,
+				Window:   lushauth.DefaultRefreshWindow,
+			},
+		},
+		{
+			name:   "refreshed claims outside the refresh window of the original authentication",
+			claims: chained,
+			expectedErr: lushauth.JWTRefreshWindowError{
+				IssuedAt: chained.AuthTime,
+				Window:   lushauth.DefaultRefreshWindow,
+			},
+		},
+		{
+			name:   "invalid claims",
+			claims: invalidClaims,
+			expectedErr: lushauth.JWTVerificationError{
+				Errors: lushauth.JWTValidationErrorID | lushauth.JWTValidationErrorIssuer | lushauth.JWTValidationErrorNotValidYet | lushauth.JWTValidationErrorUsedBeforeIssued,
+			},
+		},
+		{
+			name:        "revoked claims",
+			claims:      revokedClaims,
+			expectedErr: lushauth.JWTRevokedError{ID: "revoked"},
+		},
+		{
+			name:        "consumer can not be loaded",
+			claims:      unknown,
+			expectedErr: errLoad,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			revoked := lushauth.NewRevocationList()
+			revoked.Revoke("revoked", 0)
+			refresher := lushauth.NewRefresher("Refresher", loader)
+			refresher.Revocation = revoked
+			claims, err := refresher.Refresh(context.Background(), lushauth.RefreshableClaims{Claims: c.claims})
+			test.Equals(t, c.expectedErr, err)
+			if err == nil {
+				test.Equals(t, c.claims.ID, claims.ParentID)
+				test.NotEquals(t, c.claims.ID, claims.ID)
+				test.Equals(t, "Refresher", claims.Issuer)
+				test.Equals(t, []string{"staff"}, claims.Consumer.Roles)
+				test.Equals(t, c.claims.IssuedAt, claims.AuthTime)
+				test.Equals(t, nil, claims.Valid())
+			}
+		})
+	}
+}
+
+func TestRefresher_RefreshToken(t *testing.T) {
+	refresher := lushauth.NewRefresher("", nil)
+	raw := must(jwt.NewWithClaims(jwt.SigningMethodRS256, &expiredClaims).SignedString(rsaPriv))
+	claims, err := refresher.RefreshToken(context.Background(), raw, lushauth.RSAKeyFunc(public))
+	test.Equals(t, nil, err)
+	test.Equals(t, expiredClaims.ID, claims.ParentID)
+	test.Equals(t, expiredClaims.Issuer, claims.Issuer)
+	test.Equals(t, expiredClaims.Consumer, claims.Consumer)
+}
+
+func TestRefresher_Refresh_Once(t *testing.T) {
+	ctx := context.Background()
+	refresher := lushauth.NewRefresher("Refresher", nil)
+	refresher.Revocation = lushauth.NewRevocationList()
+
+	refreshed, err := refresher.Refresh(ctx, lushauth.RefreshableClaims{Claims: expiredClaims})
+	test.Equals(t, nil, err)
+	test.Equals(t, expiredClaims.IssuedAt, refreshed.AuthTime)
+
+	_, err = refresher.Refresh(ctx, lushauth.RefreshableClaims{Claims: expiredClaims})
+	test.Equals(t, lushauth.JWTRevokedError{ID: expiredClaims.ID}, err)
+
+	again, err := refresher.Refresh(ctx, lushauth.RefreshableClaims{Claims: refreshed})
+	test.Equals(t, nil, err)
+	test.Equals(t, refreshed.ID, again.ParentID)
+	test.Equals(t, expiredClaims.IssuedAt, again.AuthTime)
+}
+
+func TestRefresher_Refresh_OnceWithRevocationCheckers(t *testing.T) {
+	ctx := context.Background()
+	store := lushauth.RevocationCheckerFunc(func(ctx context.Context, c lushauth.Claims) (bool, error) {
+		return false, nil
+	})
+	refresher := lushauth.NewRefresher("Refresher", nil)
+	refresher.Revocation = lushauth.RevocationCheckers{lushauth.NewRevocationList(), store}
+
+	_, err := refresher.Refresh(ctx, lushauth.RefreshableClaims{Claims: expiredClaims})
+	test.Equals(t, nil, err)
+
+	_, err = refresher.Refresh(ctx, lushauth.RefreshableClaims{Claims: expiredClaims})
+	test.Equals(t, lushauth.JWTRevokedError{ID: expiredClaims.ID}, err)
+}
+
+func TestRefresher_Refresh_ActorAndService(t *testing.T) {
+	ctx := context.Background()
+	loader := lushauth.ConsumerLoaderFunc(func(ctx context.Context, c lushauth.Consumer) (lushauth.Consumer, error) {
+		if c.UUID == "" {
+			return c, errors.New("user not found")
+		}
+		return c, nil
+	})
+	refresher := lushauth.NewRefresher("Refresher", loader)
+
+	t.Run("impersonation claims are refused", func(t *testing.T) {
+		impersonated, err := lushauth.NewClaimsForImpersonation("Test", Support, Guest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = refresher.Refresh(ctx, lushauth.RefreshableClaims{Claims: impersonated})
+		test.Equals(t, lushauth.JWTRefreshActorError{Subject: Support.UUID}, err)
+	})
+	t.Run("delegation claims are refused", func(t *testing.T) {
+		delegated, err := lushauth.NewClaimsForDelegation("Test", batchJob, Guest, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = refresher.Refresh(ctx, lushauth.RefreshableClaims{Claims: delegated})
+		test.Equals(t, lushauth.JWTRefreshActorError{Subject: batchJob.ClientID}, err)
+	})
+	t.Run("service claims are refreshed for the service", func(t *testing.T) {
+		service, err := lushauth.NewClaimsForService("Test", batchJob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := refresher.Refresh(ctx, lushauth.RefreshableClaims{Claims: service})
+		test.Equals(t, nil, err)
+		test.Equals(t, &batchJob, claims.Service)
+		test.Equals(t, lushauth.Consumer{}, claims.Consumer)
+		test.Equals(t, service.ID, claims.ParentID)
+		test.Equals(t, now.Add(lushauth.DefaultServiceValidPeriod).Unix(), claims.ExpiresAt)
+	})
+}
+
+func TestRefresher_Refresh_Concurrent(t *testing.T) {
+	refresher := lushauth.NewRefresher("Refresher", nil)
+	refresher.Revocation = lushauth.NewRevocationList()
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		refreshed int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := refresher.Refresh(context.Background(), lushauth.RefreshableClaims{Claims: expiredClaims}); err == nil {
+				mu.Lock()
+				refreshed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	test.Equals(t, 1, refreshed)
+}
@@ -0,0 +1,131 @@
+package lushauth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// ResourceOwner is the attribute holding the UUID of the consumer owning a resource.
+	ResourceOwner = "owner"
+	// ResourceMarket is the attribute holding the ID of the market a resource belongs to.
+	ResourceMarket = "market"
+	// ResourceShop is the attribute holding the ID of the shop a resource belongs to.
+	ResourceShop = "shop"
+)
+
+// Resource holds the attributes of a resource that access is requested for.
+type Resource map[string]string
+
+// Owner returns the UUID of the consumer owning the resource.
+func (r Resource) Owner() string {
+	return r[ResourceOwner]
+}
+
+// Market returns the ID of the market the resource belongs to.
+func (r Resource) Market() string {
+	return r[ResourceMarket]
+}
+
+// Shop returns the ID of the shop the resource belongs to.
+func (r Resource) Shop() string {
+	return r[ResourceShop]
+}
+
+// ResourcePermitter defines the behavior of allowing access to a resource.
+type ResourcePermitter interface {
+	PermitResource(ctx context.Context, c Consumer, r Resource) error
+}
+
+// ResourcePermitterFunc allows a function to be used as a ResourcePermitter.
+type ResourcePermitterFunc func(ctx context.Context, c Consumer, r Resource) error
+
+// PermitResource calls the function with the given consumer and resource.
+func (f ResourcePermitterFunc) PermitResource(ctx context.Context, c Consumer, r Resource) error {
+	return f(ctx, c, r)
+}
+
+// PermitterPolicy adapts a Permitter to be used as a ResourcePermitter, ignoring the context and resource.
+type PermitterPolicy struct {
+	Policy Permitter
+}
+
+// PermitResource permits a consumer or returns an error.
+func (p PermitterPolicy) PermitResource(ctx context.Context, c Consumer, r Resource) error {
+	return p.Policy.Permit(c)
+}
+
+// OwnerPolicy permits consumers owning the resource.
+type OwnerPolicy struct{}
+
+// PermitResource permits a consumer or returns an error.
+func (p OwnerPolicy) PermitResource(ctx context.Context, c Consumer, r Resource) error {
+	if r.Owner() == "" || !c.HasAnyUUID(r.Owner()) {
+		return p
+	}
+	return nil
+}
+
+func (p OwnerPolicy) Error() string {
+	return fmt.Sprintf("need to be the owner of the resource")
+}
+
+// SameMarketPolicy permits consumers belonging to the market of the resource.
+// When roles are given, the consumer also needs any of them within that market.
+type SameMarketPolicy struct {
+	Roles []string
+}
+
+// PermitResource permits a consumer or returns an error.
+func (p SameMarketPolicy) PermitResource(ctx context.Context, c Consumer, r Resource) error {
+	id := r.Market()
+	if id == "" {
+		return p
+	}
+	for _, m := range c.Markets {
+		if m.ID == id && (len(p.Roles) == 0 || hasAny(m.Roles, p.Roles...)) {
+			return nil
+		}
+	}
+	return p
+}
+
+func (p SameMarketPolicy) Error() string {
+	if len(p.Roles) == 0 {
+		return fmt.Sprintf("need to be a part of the market of the resource")
+	}
+	return fmt.Sprintf("need to be a part of the market of the resource with any of the %s market roles", strings.Join(quote(p.Roles...), ", "))
+}
+
+// AnyResourcePolicy defines a policy made up of multiple other resource policies where any of them will permit access.
+type AnyResourcePolicy []ResourcePermitter
+
+// PermitResource permits a consumer or returns an error.
+func (p AnyResourcePolicy) PermitResource(ctx context.Context, c Consumer, r Resource) error {
+	var errs []error
+	for _, policy := range p {
+		if err := policy.PermitResource(ctx, c, r); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return nil
+	}
+	if len(errs) >= 1 {
+		return errs[0]
+	}
+	return nil
+}
+
+// AllResourcePolicy defines a policy made up of multiple other resource policies where all of them are required for access to be permitted.
+type AllResourcePolicy []ResourcePermitter
+
+// PermitResource permits a consumer or returns an error.
+func (p AllResourcePolicy) PermitResource(ctx context.Context, c Consumer, r Resource) error {
+	for _, policy := range p {
+		if err := policy.PermitResource(ctx, c, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
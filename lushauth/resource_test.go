@@ -0,0 +1,130 @@
+package lushauth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+)
+
+func ExampleOwnerPolicy() {
+	policy := lushauth.AnyResourcePolicy{
+		lushauth.OwnerPolicy{},
+		lushauth.PermitterPolicy{Policy: lushauth.RolePolicy{"admin"}},
+	}
+	policy.PermitResource(context.Background(), consumer, lushauth.Resource{
+		lushauth.ResourceOwner: "5d4b32f9-5954-41c3-a470-7d76317635a7",
+	})
+}
+
+func TestResourcePolicies_PermitResource(t *testing.T) {
+	var (
+		StaffPolicy         = lushauth.PermitterPolicy{Policy: lushauth.RolePolicy{"staff"}}
+		SameMarketPolicy    = lushauth.SameMarketPolicy{}
+		SameMarketMgrPolicy = lushauth.SameMarketPolicy{Roles: []string{"manager"}}
+		EditOrderPolicy     = lushauth.AllResourcePolicy{StaffPolicy, SameMarketPolicy}
+		OwnOrAdminPolicy    = lushauth.AnyResourcePolicy{
+			lushauth.OwnerPolicy{},
+			lushauth.PermitterPolicy{Policy: lushauth.RolePolicy{"admin"}},
+		}
+		BritishOrder = lushauth.Resource{lushauth.ResourceMarket: "gb", lushauth.ResourceShop: "london"}
+		SwedishOrder = lushauth.Resource{lushauth.ResourceMarket: "se"}
+		StaffProfile = lushauth.Resource{lushauth.ResourceOwner: PolicyUserID}
+	)
+	type Test struct {
+		name     string
+		consumer lushauth.Consumer
+		policy   lushauth.ResourcePermitter
+		resource lushauth.Resource
+		expected error
+	}
+	cases := []Test{
+		{
+			name:     "owner policy with owning consumer",
+			consumer: Staff,
+			policy:   lushauth.OwnerPolicy{},
+			resource: StaffProfile,
+		},
+		{
+			name:     "owner policy with other consumer",
+			consumer: Guest,
+			policy:   lushauth.OwnerPolicy{},
+			resource: StaffProfile,
+			expected: lushauth.OwnerPolicy{},
+		},
+		{
+			name:     "owner policy with resource without owner",
+			consumer: lushauth.Consumer{},
+			policy:   lushauth.OwnerPolicy{},
+			resource: BritishOrder,
+			expected: lushauth.OwnerPolicy{},
+		},
+		{
+			name:     "same market policy with consumer in the market",
+			consumer: Staff,
+			policy:   SameMarketPolicy,
+			resource: BritishOrder,
+		},
+		{
+			name:     "same market policy with consumer in another market",
+			consumer: Staff,
+			policy:   SameMarketPolicy,
+			resource: SwedishOrder,
+			expected: SameMarketPolicy,
+		},
+		{
+			name:     "same market policy with roles and consumer lacking the role",
+			consumer: Staff,
+			policy:   SameMarketMgrPolicy,
+			resource: BritishOrder,
+			expected: SameMarketMgrPolicy,
+		},
+		{
+			name:     "same market policy with roles and consumer having the role",
+			consumer: Manager,
+			policy:   SameMarketMgrPolicy,
+			resource: SwedishOrder,
+		},
+		{
+			name:     "edit order policy with staff in the market",
+			consumer: Staff,
+			policy:   EditOrderPolicy,
+			resource: BritishOrder,
+		},
+		{
+			name:     "edit order policy with guest",
+			consumer: Guest,
+			policy:   EditOrderPolicy,
+			resource: BritishOrder,
+			expected: lushauth.RolePolicy{"staff"},
+		},
+		{
+			name:     "own or admin policy with admin",
+			consumer: Admin,
+			policy:   OwnOrAdminPolicy,
+			resource: lushauth.Resource{lushauth.ResourceOwner: GuestID},
+		},
+		{
+			name:     "own or admin policy with other consumer",
+			consumer: Staff,
+			policy:   OwnOrAdminPolicy,
+			resource: lushauth.Resource{lushauth.ResourceOwner: GuestID},
+			expected: lushauth.OwnerPolicy{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			AssertPermitResource(t, c.expected, c.policy, c.consumer, c.resource)
+		})
+	}
+}
+
+func AssertPermitResource(t *testing.T, expected error, p lushauth.ResourcePermitter, c lushauth.Consumer, r lushauth.Resource) {
+	t.Helper()
+	err := p.PermitResource(context.Background(), c, r)
+	if err != nil {
+		t.Log(err)
+	}
+	test.Equals(t, expected, err)
+}
@@ -0,0 +1,175 @@
+package lushauth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// RevocationChecker defines the behavior of checking whether a set of claims has been revoked.
+type RevocationChecker interface {
+	Revoked(ctx context.Context, c Claims) (bool, error)
+}
+
+// RevocationCheckerFunc allows a function to be used as a RevocationChecker.
+// It can be used to hook up external stores such as a database or a cache.
+type RevocationCheckerFunc func(ctx context.Context, c Claims) (bool, error)
+
+// Revoked calls the function with the given claims.
+func (f RevocationCheckerFunc) Revoked(ctx context.Context, c Claims) (bool, error) {
+	return f(ctx, c)
+}
+
+// RevocationCheckers defines a set of revocation checkers where any of them can revoke a set of claims.
+type RevocationCheckers []RevocationChecker
+
+// Revoked checks the claims against every checker in order, stopping at the first revocation or error.
+func (rcs RevocationCheckers) Revoked(ctx context.Context, c Claims) (bool, error) {
+	for _, rc := range rcs {
+		revoked, err := rc.Revoked(ctx, c)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	return false, nil
+}
+
+// RevokeIfAbsent revokes a token id with every checker that is also a Revoker,
+// and reports whether none of them had already revoked it.
+func (rcs RevocationCheckers) RevokeIfAbsent(id string, expiresAt int64) bool {
+	absent := true
+	for _, rc := range rcs {
+		if r, ok := rc.(Revoker); ok && !r.RevokeIfAbsent(id, expiresAt) {
+			absent = false
+		}
+	}
+	return absent
+}
+
+// Revoker defines the behavior of atomically revoking a token id until the given expiry as a unix timestamp,
+// reporting whether the token id was not already revoked.
+type Revoker interface {
+	RevokeIfAbsent(id string, expiresAt int64) bool
+}
+
+// JWTRevokedError happens when the claims of a token have been revoked.
+type JWTRevokedError struct {
+	ID string
+}
+
+func (e JWTRevokedError) Error() string {
+	return fmt.Sprintf("token has been revoked: %s", e.ID)
+}
+
+// CheckRevocation asks a revocation checker about a set of claims and returns an error if they have been revoked.
+func CheckRevocation(ctx context.Context, rc RevocationChecker, c Claims) error {
+	revoked, err := rc.Revoked(ctx, c)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return JWTRevokedError{c.ID}
+	}
+	return nil
+}
+
+// minPruneThreshold is the least number of entries in a revocation list before they are pruned when revoking.
+const minPruneThreshold = 64
+
+// RevocationList is an in-memory deny list of token ids (JTI).
+// Entries are kept until the revoked token would have expired anyway.
+// Expired entries are pruned when revoking once the list has doubled in size since it was last pruned,
+// so that the list does not grow beyond the number of tokens that are revoked and not yet expired.
+type RevocationList struct {
+	mu      sync.RWMutex
+	entries map[string]int64
+	pruneAt int
+}
+
+// NewRevocationList creates an empty revocation list.
+func NewRevocationList() *RevocationList {
+	return &RevocationList{
+		entries: make(map[string]int64),
+		pruneAt: minPruneThreshold,
+	}
+}
+
+// Revoke adds a token id to the list until the given expiry as a unix timestamp.
+// An expiry of zero will keep the token id in the list indefinitely.
+func (l *RevocationList) Revoke(id string, expiresAt int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.revoke(id, expiresAt)
+}
+
+// RevokeIfAbsent adds a token id to the list until the given expiry as a unix timestamp,
+// unless it is already in the list and has not expired, and reports whether it was added.
+func (l *RevocationList) RevokeIfAbsent(id string, expiresAt int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if existing, ok := l.entries[id]; ok && !expired(existing, TimeFunc().Unix()) {
+		return false
+	}
+	l.revoke(id, expiresAt)
+	return true
+}
+
+// revoke adds a token id to the list while holding the lock, pruning the list once it has doubled in size.
+func (l *RevocationList) revoke(id string, expiresAt int64) {
+	l.entries[id] = expiresAt
+	if len(l.entries) >= l.pruneAt {
+		l.prune(TimeFunc().Unix())
+		l.pruneAt = 2 * len(l.entries)
+		if l.pruneAt < minPruneThreshold {
+			l.pruneAt = minPruneThreshold
+		}
+	}
+}
+
+// RevokeClaims adds the id of a set of claims to the list until the claims expire.
+func (l *RevocationList) RevokeClaims(c Claims) {
+	l.Revoke(c.ID, c.ExpiresAt)
+}
+
+// Revoked checks if the id of a set of claims is in the list.
+func (l *RevocationList) Revoked(ctx context.Context, c Claims) (bool, error) {
+	l.mu.RLock()
+	expiresAt, ok := l.entries[c.ID]
+	l.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	return !expired(expiresAt, TimeFunc().Unix()), nil
+}
+
+// Prune removes all expired token ids from the list and returns how many were removed.
+func (l *RevocationList) Prune() int {
+	now := TimeFunc().Unix()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.prune(now)
+}
+
+// prune removes all expired token ids from the list while holding the lock.
+func (l *RevocationList) prune(now int64) int {
+	var n int
+	for id, expiresAt := range l.entries {
+		if expired(expiresAt, now) {
+			delete(l.entries, id)
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of token ids in the list, including the ones not yet pruned.
+func (l *RevocationList) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.entries)
+}
+
+// expired checks if an expiry unix timestamp has passed.
+func expired(expiresAt, now int64) bool {
+	return expiresAt != 0 && now > expiresAt
+}
@@ -0,0 +1,100 @@
+package lushauth_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+)
+
+func ExampleRevocationList() {
+	list := lushauth.NewRevocationList()
+	list.RevokeClaims(validClaims)
+	lushauth.CheckRevocation(context.Background(), list, validClaims)
+}
+
+func TestRevocationList(t *testing.T) {
+	ctx := context.Background()
+	list := lushauth.NewRevocationList()
+	list.RevokeClaims(validClaims)
+	list.Revoke("expired", now.Add(-1*time.Minute).Unix())
+	list.Revoke("forever", 0)
+
+	t.Run("when the token has been revoked", func(t *testing.T) {
+		test.Equals(t, lushauth.JWTRevokedError{ID: validClaims.ID}, lushauth.CheckRevocation(ctx, list, validClaims))
+	})
+	t.Run("when the token has not been revoked", func(t *testing.T) {
+		test.Equals(t, nil, lushauth.CheckRevocation(ctx, list, lushauth.Claims{ID: "other"}))
+	})
+	t.Run("when the revocation has expired", func(t *testing.T) {
+		test.Equals(t, nil, lushauth.CheckRevocation(ctx, list, lushauth.Claims{ID: "expired"}))
+	})
+	t.Run("when the revocation never expires", func(t *testing.T) {
+		revoked, err := list.Revoked(ctx, lushauth.Claims{ID: "forever"})
+		test.Equals(t, nil, err)
+		test.Equals(t, true, revoked)
+	})
+	t.Run("when pruning expired revocations", func(t *testing.T) {
+		test.Equals(t, 1, list.Prune())
+		test.Equals(t, 2, list.Len())
+	})
+}
+
+func TestRevocationCheckers(t *testing.T) {
+	ctx := context.Background()
+	errStore := errors.New("store unavailable")
+	list := lushauth.NewRevocationList()
+	list.RevokeClaims(validClaims)
+	store := lushauth.RevocationCheckerFunc(func(ctx context.Context, c lushauth.Claims) (bool, error) {
+		if c.ID == "broken" {
+			return false, errStore
+		}
+		return c.ID == "external", nil
+	})
+	checkers := lushauth.RevocationCheckers{list, store}
+
+	t.Run("when revoked in memory", func(t *testing.T) {
+		test.Equals(t, lushauth.JWTRevokedError{ID: validClaims.ID}, lushauth.CheckRevocation(ctx, checkers, validClaims))
+	})
+	t.Run("when revoked in the external store", func(t *testing.T) {
+		test.Equals(t, lushauth.JWTRevokedError{ID: "external"}, lushauth.CheckRevocation(ctx, checkers, lushauth.Claims{ID: "external"}))
+	})
+	t.Run("when the external store fails", func(t *testing.T) {
+		test.Equals(t, errStore, lushauth.CheckRevocation(ctx, checkers, lushauth.Claims{ID: "broken"}))
+	})
+	t.Run("when not revoked anywhere", func(t *testing.T) {
+		test.Equals(t, nil, lushauth.CheckRevocation(ctx, checkers, lushauth.Claims{ID: "other"}))
+	})
+	t.Run("when revoking with the in-memory list", func(t *testing.T) {
+		test.Equals(t, true, checkers.RevokeIfAbsent("other", 0))
+		test.Equals(t, lushauth.JWTRevokedError{ID: "other"}, lushauth.CheckRevocation(ctx, checkers, lushauth.Claims{ID: "other"}))
+		test.Equals(t, false, checkers.RevokeIfAbsent("other", 0))
+	})
+}
+
+func TestRevocationList_PrunesWhenRevoking(t *testing.T) {
+	list := lushauth.NewRevocationList()
+	for i := 0; i < 1000; i++ {
+		list.Revoke(fmt.Sprintf("expired-%d", i), now.Add(-1*time.Minute).Unix())
+	}
+	test.Equals(t, true, list.Len() < 100)
+	list.RevokeClaims(validClaims)
+	revoked, err := list.Revoked(context.Background(), validClaims)
+	test.Equals(t, nil, err)
+	test.Equals(t, true, revoked)
+}
+
+func TestRevocationList_RevokeIfAbsent(t *testing.T) {
+	list := lushauth.NewRevocationList()
+	list.Revoke("expired", now.Add(-1*time.Minute).Unix())
+	test.Equals(t, true, list.RevokeIfAbsent("new", 0))
+	test.Equals(t, false, list.RevokeIfAbsent("new", 0))
+	test.Equals(t, true, list.RevokeIfAbsent("expired", now.Add(time.Minute).Unix()))
+	revoked, err := list.Revoked(context.Background(), lushauth.Claims{ID: "expired"})
+	test.Equals(t, nil, err)
+	test.Equals(t, true, revoked)
+}
@@ -0,0 +1,112 @@
+package lushauth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RoleDefinition describes the grants given by a role and the other roles it includes.
+type RoleDefinition struct {
+	// Grants are the grants given to any consumer with the role.
+	Grants []string `json:"grants"`
+	// Includes are the roles whose grants are inherited by the role.
+	// e.g. admin includes staff
+	Includes []string `json:"includes"`
+}
+
+// RoleCatalogue maps roles to the grants they give.
+type RoleCatalogue map[string]RoleDefinition
+
+// RoleCatalogueError happens when a role catalogue is inconsistent.
+type RoleCatalogueError struct {
+	Role    string
+	Problem string
+}
+
+func (e RoleCatalogueError) Error() string {
+	return fmt.Sprintf("role %q %s", e.Role, e.Problem)
+}
+
+// Validate checks that every included role is defined and that no role includes itself.
+func (rc RoleCatalogue) Validate() error {
+	for role, def := range rc {
+		for _, include := range def.Includes {
+			if _, ok := rc[include]; !ok {
+				return RoleCatalogueError{role, fmt.Sprintf("includes undefined role %q", include)}
+			}
+		}
+		if path, ok := rc.cycle(role, role, nil); ok {
+			return RoleCatalogueError{role, fmt.Sprintf("includes itself through %s", strings.Join(quote(path...), ", "))}
+		}
+	}
+	return nil
+}
+
+// cycle looks for a path of includes from a role back to the origin role.
+func (rc RoleCatalogue) cycle(origin, role string, path []string) ([]string, bool) {
+	for _, include := range rc[role].Includes {
+		if include == origin {
+			return append(path, include), true
+		}
+		if hasAny(path, include) {
+			continue
+		}
+		if p, ok := rc.cycle(origin, include, append(path, include)); ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Roles expands a set of roles into the set of roles including every inherited role.
+func (rc RoleCatalogue) Roles(roles ...string) []string {
+	var expanded []string
+	for _, role := range roles {
+		expanded = rc.expand(expanded, role)
+	}
+	return expanded
+}
+
+// expand adds a role and the roles it includes to a set of roles.
+func (rc RoleCatalogue) expand(set []string, role string) []string {
+	if hasAny(set, role) {
+		return set
+	}
+	set = append(set, role)
+	for _, include := range rc[role].Includes {
+		set = rc.expand(set, include)
+	}
+	return set
+}
+
+// Grants returns the grants given by a set of roles, including the grants of any inherited role.
+func (rc RoleCatalogue) Grants(roles ...string) []string {
+	var grants []string
+	for _, role := range rc.Roles(roles...) {
+		for _, grant := range rc[role].Grants {
+			if !hasAny(grants, grant) {
+				grants = append(grants, grant)
+			}
+		}
+	}
+	return grants
+}
+
+// Expand returns a copy of the consumer with its effective roles and grants.
+func (rc RoleCatalogue) Expand(c Consumer) Consumer {
+	c.Grants = c.EffectiveGrants(rc)
+	c.Roles = rc.Roles(c.Roles...)
+	return c
+}
+
+// EffectiveGrants returns the grants of a consumer combined with the grants given by its roles.
+func (c *Consumer) EffectiveGrants(rc RoleCatalogue) []string {
+	grants := make([]string, 0, len(c.Grants))
+	grants = append(grants, c.Grants...)
+	for _, grant := range rc.Grants(c.Roles...) {
+		if !hasAny(grants, grant) {
+			grants = append(grants, grant)
+		}
+	}
+	return grants
+}
@@ -0,0 +1,155 @@
+package lushauth_test
+
+import (
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+)
+
+var catalogue = lushauth.RoleCatalogue{
+	"guest": {
+		Grants: []string{"pages.read"},
+	},
+	"staff": {
+		Grants:   []string{"products.read", "tills.read"},
+		Includes: []string{"guest"},
+	},
+	"creator": {
+		Grants:   []string{"pages.create"},
+		Includes: []string{"staff"},
+	},
+	"admin": {
+		Grants:   []string{"users.*"},
+		Includes: []string{"creator", "staff"},
+	},
+}
+
+func ExampleRoleCatalogue() {
+	policy := lushauth.CataloguePolicy{
+		Catalogue: catalogue,
+		Policy:    lushauth.GrantPolicy{"pages.create"},
+	}
+	policy.Permit(consumer)
+}
+
+func TestRoleCatalogue_Validate(t *testing.T) {
+	cases := []struct {
+		name      string
+		catalogue lushauth.RoleCatalogue
+		expected  error
+	}{
+		{
+			name:      "valid catalogue",
+			catalogue: catalogue,
+		},
+		{
+			name: "catalogue including an undefined role",
+			catalogue: lushauth.RoleCatalogue{
+				"admin": {Includes: []string{"staff"}},
+			},
+			expected: lushauth.RoleCatalogueError{Role: "admin", Problem: `includes undefined role "staff"`},
+		},
+		{
+			name: "catalogue with a role including itself",
+			catalogue: lushauth.RoleCatalogue{
+				"admin": {Includes: []string{"admin"}},
+			},
+			expected: lushauth.RoleCatalogueError{Role: "admin", Problem: `includes itself through "admin"`},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			test.Equals(t, c.expected, c.catalogue.Validate())
+		})
+	}
+}
+
+func TestRoleCatalogue_Roles(t *testing.T) {
+	test.Equals(t, []string{"admin", "creator", "staff", "guest"}, catalogue.Roles("admin"))
+	test.Equals(t, []string{"unknown"}, catalogue.Roles("unknown"))
+	test.Equals(t, []string(nil), catalogue.Roles())
+}
+
+func TestConsumer_EffectiveGrants(t *testing.T) {
+	cases := []struct {
+		name     string
+		consumer lushauth.Consumer
+		expected []string
+	}{
+		{
+			name:     "consumer without roles",
+			consumer: lushauth.Consumer{Grants: []string{"orders.read"}},
+			expected: []string{"orders.read"},
+		},
+		{
+			name:     "consumer with an inheriting role",
+			consumer: lushauth.Consumer{Roles: []string{"creator"}, Grants: []string{"pages.read"}},
+			expected: []string{"pages.read", "pages.create", "products.read", "tills.read"},
+		},
+		{
+			name:     "consumer with an undefined role",
+			consumer: lushauth.Consumer{Roles: []string{"unknown"}},
+			expected: []string{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			test.Equals(t, c.expected, c.consumer.EffectiveGrants(catalogue))
+		})
+	}
+}
+
+func TestCataloguePolicy_Permit(t *testing.T) {
+	var (
+		StaffPolicy = lushauth.CataloguePolicy{
+			Catalogue: catalogue,
+			Policy:    lushauth.RolePolicy{"staff"},
+		}
+		CreatePagesPolicy = lushauth.CataloguePolicy{
+			Catalogue: catalogue,
+			Policy:    lushauth.GrantPolicy{"pages.create"},
+		}
+	)
+	type Test struct {
+		name     string
+		consumer lushauth.Consumer
+		policy   lushauth.Permitter
+		expected error
+	}
+	cases := []Test{
+		{
+			name:     "staff policy with admin consumer",
+			consumer: Admin,
+			policy:   StaffPolicy,
+			expected: nil,
+		},
+		{
+			name:     "staff policy with guest consumer",
+			consumer: Guest,
+			policy:   StaffPolicy,
+			expected: lushauth.RolePolicy{"staff"},
+		},
+		{
+			name:     "create pages policy with admin consumer",
+			consumer: Admin,
+			policy:   CreatePagesPolicy,
+			expected: nil,
+		},
+		{
+			name:     "create pages policy with staff consumer",
+			consumer: Staff,
+			policy:   CreatePagesPolicy,
+			expected: lushauth.GrantPolicy{"pages.create"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			AssertPermit(t, c.expected, c.policy, c.consumer)
+		})
+	}
+	t.Run("consumer is not modified", func(t *testing.T) {
+		StaffPolicy.Permit(Admin)
+		test.Equals(t, []string{"admin"}, Admin.Roles)
+	})
+}
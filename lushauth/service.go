@@ -0,0 +1,113 @@
+package lushauth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/LUSHDigital/uuid"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Service represents a machine consumer of the LUSH infrastructure, such as a batch job or another service.
+type Service struct {
+	// ClientID is the unique identifier for a service.
+	ClientID string `json:"client_id"`
+	// Scopes are what a service is allowed to do, matched like grants.
+	// e.g. orders.read or products.*
+	Scopes []string `json:"scopes"`
+}
+
+// HasAnyScope checks if a service possess any of a given set of scopes, taking wildcards into account.
+func (s *Service) HasAnyScope(scopes ...string) bool {
+	return hasAnyGrant(s.Scopes, scopes...)
+}
+
+// NewClaimsForService spawns new short lived claims for a service.
+func NewClaimsForService(issuer string, service Service) (Claims, error) {
+	var c Claims
+	now := TimeFunc()
+	id, err := uuid.NewV4()
+	if err != nil {
+		return c, err
+	}
+	return Claims{
+		ID:        id.String(),
+		Issuer:    issuer,
+		Subject:   service.ClientID,
+		ExpiresAt: now.Add(DefaultServiceValidPeriod).Unix(),
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		Service:   &service,
+	}, nil
+}
+
+// TokenIssuer defines the behavior of signing claims into a token, such as the issuer of the core auth package.
+type TokenIssuer interface {
+	Issue(claims jwt.Claims) (string, error)
+}
+
+// ServiceIssuer issues short lived tokens for a service.
+// The tokens can be used with the client interceptors and transports of the lushauthmw package.
+type ServiceIssuer struct {
+	Issuer  TokenIssuer
+	Name    string
+	Service Service
+}
+
+// NewServiceIssuer creates a service issuer signing tokens with a token issuer.
+func NewServiceIssuer(issuer TokenIssuer, name string, service Service) *ServiceIssuer {
+	return &ServiceIssuer{
+		Issuer:  issuer,
+		Name:    name,
+		Service: service,
+	}
+}
+
+// Issue signs new claims for the service.
+func (i *ServiceIssuer) Issue() (string, error) {
+	claims, err := NewClaimsForService(i.Name, i.Service)
+	if err != nil {
+		return "", err
+	}
+	return i.Issuer.Issue(&claims)
+}
+
+// Token signs new claims for the service, so that the service issuer can be used as a token source.
+// Wrap it in a CachingTokenSource to avoid signing new claims for every call.
+func (i *ServiceIssuer) Token(ctx context.Context) (string, error) {
+	return i.Issue()
+}
+
+// ServicePolicy defines what services to grant access for, by client id.
+type ServicePolicy []string
+
+// PermitResource permits a service or returns an error.
+func (p ServicePolicy) PermitResource(ctx context.Context, c Consumer, r Resource) error {
+	service, ok := ServiceFromContext(ctx)
+	if !ok || !hasAny(p, service.ClientID) {
+		return p
+	}
+	return nil
+}
+
+func (p ServicePolicy) Error() string {
+	return fmt.Sprintf("need to be any of the %s services", strings.Join(quote(p...), ", "))
+}
+
+// ScopePolicy defines what scopes are required for a service to be granted access.
+type ScopePolicy []string
+
+// PermitResource permits a service or returns an error.
+func (p ScopePolicy) PermitResource(ctx context.Context, c Consumer, r Resource) error {
+	service, ok := ServiceFromContext(ctx)
+	if !ok || !service.HasAnyScope(p...) {
+		return p
+	}
+	return nil
+}
+
+func (p ScopePolicy) Error() string {
+	return fmt.Sprintf("need to be a service with any of the %s scopes", strings.Join(quote(p...), ", "))
+}
@@ -0,0 +1,112 @@
+package lushauth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+type rsaIssuer struct{}
+
+func (rsaIssuer) Issue(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(rsaPriv)
+}
+
+var batchJob = lushauth.Service{
+	ClientID: "stock-sync",
+	Scopes:   []string{"products.read", "stock.*"},
+}
+
+func ExampleServiceIssuer() {
+	issuer := lushauth.NewServiceIssuer(rsaIssuer{}, "stock-sync", lushauth.Service{
+		ClientID: "stock-sync",
+		Scopes:   []string{"stock.update"},
+	})
+	issuer.Issue()
+}
+
+func TestNewClaimsForService(t *testing.T) {
+	c, err := lushauth.NewClaimsForService("Test", batchJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Equals(t, batchJob, *c.Service)
+	test.Equals(t, batchJob.ClientID, c.Subject)
+	test.Equals(t, now.Add(lushauth.DefaultServiceValidPeriod).Unix(), c.ExpiresAt)
+	test.Equals(t, nil, c.Valid())
+}
+
+func TestServiceIssuer_Issue(t *testing.T) {
+	raw, err := lushauth.NewServiceIssuer(rsaIssuer{}, "Test", batchJob).Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims lushauth.Claims
+	if _, err := jwt.ParseWithClaims(raw, &claims, lushauth.RSAKeyFunc(public)); err != nil {
+		t.Fatal(err)
+	}
+	test.Equals(t, batchJob, *claims.Service)
+	test.Equals(t, lushauth.Consumer{}, claims.Consumer)
+}
+
+func TestService_HasAnyScope(t *testing.T) {
+	test.Equals(t, true, batchJob.HasAnyScope("products.read"))
+	test.Equals(t, true, batchJob.HasAnyScope("stock.update"))
+	test.Equals(t, false, batchJob.HasAnyScope("products.update"))
+}
+
+func TestServicePolicies_PermitResource(t *testing.T) {
+	var (
+		StockSyncPolicy   = lushauth.ServicePolicy{"stock-sync"}
+		UpdateStockPolicy = lushauth.ScopePolicy{"stock.update"}
+		UpdateUsersPolicy = lushauth.ScopePolicy{"users.update"}
+	)
+	serviceCtx := lushauth.ContextWithClaims(context.Background(), lushauth.Claims{Service: &batchJob})
+	consumerCtx := lushauth.ContextWithClaims(context.Background(), lushauth.Claims{Consumer: Admin})
+	cases := []struct {
+		name     string
+		ctx      context.Context
+		policy   lushauth.ResourcePermitter
+		expected error
+	}{
+		{
+			name:   "service policy with matching service",
+			ctx:    serviceCtx,
+			policy: StockSyncPolicy,
+		},
+		{
+			name:     "service policy with consumer",
+			ctx:      consumerCtx,
+			policy:   StockSyncPolicy,
+			expected: StockSyncPolicy,
+		},
+		{
+			name:   "scope policy with service having a matching scope",
+			ctx:    serviceCtx,
+			policy: UpdateStockPolicy,
+		},
+		{
+			name:     "scope policy with service lacking the scope",
+			ctx:      serviceCtx,
+			policy:   UpdateUsersPolicy,
+			expected: UpdateUsersPolicy,
+		},
+		{
+			name: "any policy with admin consumer",
+			ctx:  consumerCtx,
+			policy: lushauth.AnyResourcePolicy{
+				UpdateUsersPolicy,
+				lushauth.PermitterPolicy{Policy: lushauth.RolePolicy{"admin"}},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.policy.PermitResource(c.ctx, lushauth.ConsumerFromContext(c.ctx), nil)
+			test.Equals(t, c.expected, err)
+		})
+	}
+}
@@ -11,4 +11,13 @@ var (
 
 	// DefaultValidPeriod is the period a set of claims are valid.
 	DefaultValidPeriod = 60 * time.Minute
+
+	// DefaultServiceValidPeriod is the period a set of claims for a service are valid.
+	DefaultServiceValidPeriod = 5 * time.Minute
+
+	// DefaultRefreshWindow is the period after being issued that a set of claims can be refreshed.
+	DefaultRefreshWindow = 24 * time.Hour
+
+	// DefaultTokenLeeway is the period before a token expires that a caching token source gets a new one.
+	DefaultTokenLeeway = 30 * time.Second
 )
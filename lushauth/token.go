@@ -0,0 +1,108 @@
+package lushauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// TokenSource defines the behavior of providing a token to authenticate an outgoing call with.
+// Token sources are consulted for every call, so that they can hand out a new token when the previous one expires.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc allows a function to be used as a token source.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token returns a token.
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticTokenSource returns a token source which always returns the same token.
+func StaticTokenSource(token string) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// CachingTokenSource hands out the token of a source until it is about to expire, after which it gets a new one.
+// Concurrent calls share a single request for a new token, while callers whose context is done stop waiting for it.
+type CachingTokenSource struct {
+	// Source is the token source to get new tokens from.
+	Source TokenSource
+	// Leeway is how long before the expiry of a token a new one is fetched.
+	Leeway time.Duration
+
+	mu        sync.Mutex
+	token     string
+	expiresAt int64
+	fetching  *tokenFetch
+}
+
+// tokenFetch is a request for a new token which concurrent callers wait for.
+type tokenFetch struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// NewCachingTokenSource creates a caching token source for a source using the DefaultTokenLeeway.
+func NewCachingTokenSource(source TokenSource) *CachingTokenSource {
+	return &CachingTokenSource{
+		Source: source,
+		Leeway: DefaultTokenLeeway,
+	}
+}
+
+// Token returns the cached token or a new one from the source when the cached token is about to expire.
+// The expiry is read from the exp claim of the token without verifying it, where tokens without one are cached forever.
+// Tokens that are not a JWT are handed out without being cached, so the source is consulted for every call.
+func (s *CachingTokenSource) Token(ctx context.Context) (string, error) {
+	for {
+		s.mu.Lock()
+		if s.token != "" && (s.expiresAt == 0 || TimeFunc().Add(s.Leeway).Unix() < s.expiresAt) {
+			token := s.token
+			s.mu.Unlock()
+			return token, nil
+		}
+		if f := s.fetching; f != nil {
+			s.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-f.done:
+			}
+			// Try again when the caller fetching the token gave up, rather than failing with their context error.
+			if errors.Is(f.err, context.Canceled) || errors.Is(f.err, context.DeadlineExceeded) {
+				continue
+			}
+			return f.token, f.err
+		}
+		f := &tokenFetch{done: make(chan struct{})}
+		s.fetching = f
+		s.mu.Unlock()
+		return s.fetch(ctx, f)
+	}
+}
+
+// fetch gets a new token from the source and caches it, after which the callers waiting for it are released.
+func (s *CachingTokenSource) fetch(ctx context.Context, f *tokenFetch) (string, error) {
+	f.token, f.err = s.Source.Token(ctx)
+	s.mu.Lock()
+	defer close(f.done)
+	defer s.mu.Unlock()
+	s.fetching = nil
+	if f.err != nil {
+		return "", f.err
+	}
+	var claims jwt.StandardClaims
+	if _, _, err := new(jwt.Parser).ParseUnverified(f.token, &claims); err == nil {
+		s.token, s.expiresAt = f.token, claims.ExpiresAt
+	}
+	return f.token, nil
+}
@@ -0,0 +1,147 @@
+package lushauth_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/test"
+)
+
+func ExampleCachingTokenSource() {
+	issuer := lushauth.NewServiceIssuer(rsaIssuer{}, "stock-sync", lushauth.Service{
+		ClientID: "stock-sync",
+		Scopes:   []string{"stock.update"},
+	})
+	source := lushauth.NewCachingTokenSource(issuer)
+	source.Token(context.Background())
+}
+
+func TestCachingTokenSource_Token(t *testing.T) {
+	defer func() { lushauth.TimeFunc = func() time.Time { return now } }()
+	var (
+		current = now
+		calls   int
+	)
+	lushauth.TimeFunc = func() time.Time { return current }
+	issuer := lushauth.NewServiceIssuer(rsaIssuer{}, "Test", batchJob)
+	source := lushauth.NewCachingTokenSource(lushauth.TokenSourceFunc(func(ctx context.Context) (string, error) {
+		calls++
+		return issuer.Token(ctx)
+	}))
+
+	first, err := source.Token(context.Background())
+	test.Equals(t, nil, err)
+	test.Equals(t, 1, calls)
+
+	current = now.Add(4 * time.Minute)
+	cached, err := source.Token(context.Background())
+	test.Equals(t, nil, err)
+	test.Equals(t, first, cached)
+	test.Equals(t, 1, calls)
+
+	current = now.Add(4*time.Minute + 31*time.Second)
+	refreshed, err := source.Token(context.Background())
+	test.Equals(t, nil, err)
+	test.NotEquals(t, first, refreshed)
+	test.Equals(t, 2, calls)
+}
+
+func TestCachingTokenSource_TokenError(t *testing.T) {
+	errSource := errors.New("auth service unavailable")
+	source := lushauth.NewCachingTokenSource(lushauth.TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return "", errSource
+	}))
+	_, err := source.Token(context.Background())
+	test.Equals(t, errSource, err)
+}
+
+func TestCachingTokenSource_TokenNotJWT(t *testing.T) {
+	var calls int
+	source := lushauth.NewCachingTokenSource(lushauth.TokenSourceFunc(func(ctx context.Context) (string, error) {
+		calls++
+		return "not a token", nil
+	}))
+	for i := 1; i <= 2; i++ {
+		token, err := source.Token(context.Background())
+		test.Equals(t, nil, err)
+		test.Equals(t, "not a token", token)
+		test.Equals(t, i, calls)
+	}
+}
+
+func TestCachingTokenSource_TokenConcurrent(t *testing.T) {
+	var (
+		calls   int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	issuer := lushauth.NewServiceIssuer(rsaIssuer{}, "Test", batchJob)
+	source := lushauth.NewCachingTokenSource(lushauth.TokenSourceFunc(func(ctx context.Context) (string, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return issuer.Token(ctx)
+	}))
+
+	type result struct {
+		token string
+		err   error
+	}
+	results := make(chan result, 2)
+	fetch := func() {
+		token, err := source.Token(context.Background())
+		results <- result{token, err}
+	}
+	go fetch()
+	<-started
+	go fetch()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := source.Token(ctx)
+	test.Equals(t, context.Canceled, err)
+
+	close(release)
+	first, second := <-results, <-results
+	test.Equals(t, nil, first.err)
+	test.Equals(t, nil, second.err)
+	test.Equals(t, first.token, second.token)
+	test.Equals(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestCachingTokenSource_TokenCanceledFetch(t *testing.T) {
+	var (
+		calls   int32
+		started = make(chan struct{})
+	)
+	issuer := lushauth.NewServiceIssuer(rsaIssuer{}, "Test", batchJob)
+	source := lushauth.NewCachingTokenSource(lushauth.TokenSourceFunc(func(ctx context.Context) (string, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		return issuer.Token(ctx)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := source.Token(ctx)
+		canceled <- err
+	}()
+	<-started
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := source.Token(context.Background())
+		waiting <- err
+	}()
+	cancel()
+	test.Equals(t, context.Canceled, <-canceled)
+	test.Equals(t, nil, <-waiting)
+}
@@ -1,7 +1,13 @@
 package lushauthmw
 
 import (
+	"crypto"
 	"crypto/rsa"
+	"strings"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/auth"
+	"github.com/dgrijalva/jwt-go"
 )
 
 // CopierRenewer represents the combination of a Copier and Renewer interface
@@ -9,3 +15,112 @@ type CopierRenewer interface {
 	Copy() rsa.PublicKey
 	Renew()
 }
+
+// AuthMode defines how the auth middlewares treat requests without valid credentials.
+type AuthMode int
+
+const (
+	// AuthRequired refuses requests with missing or invalid credentials.
+	AuthRequired AuthMode = iota
+	// AuthOptional lets requests without credentials through as the anonymous consumer but refuses invalid credentials.
+	AuthOptional
+	// AuthAnonymous lets requests with missing or invalid credentials through as the anonymous consumer.
+	AuthAnonymous
+)
+
+// anonymous checks if a request that failed to authenticate should continue as the anonymous consumer.
+func (m AuthMode) anonymous(missing bool) bool {
+	switch m {
+	case AuthOptional:
+		return missing
+	case AuthAnonymous:
+		return true
+	default:
+		return false
+	}
+}
+
+// Option represents behaviour for applying options to the auth middlewares.
+type Option func(*options)
+
+type options struct {
+	revocation lushauth.RevocationChecker
+	extra      []lushauth.ExtraClaim
+	mode       AuthMode
+	extractors []TokenExtractor
+	hooks      EventHooks
+	keyfunc    auth.PublicKeyFunc
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{extractors: DefaultTokenExtractors, keyfunc: lushauth.RSAKeyFunc}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithRevocationChecker makes the auth middlewares reject tokens that have been revoked.
+func WithRevocationChecker(rc lushauth.RevocationChecker) Option {
+	return func(o *options) {
+		o.revocation = rc
+	}
+}
+
+// WithExtraClaims makes the auth middlewares reject tokens that are missing any of the extra claims or where they are invalid.
+func WithExtraClaims(claims ...lushauth.ExtraClaim) Option {
+	return func(o *options) {
+		o.extra = append(o.extra, claims...)
+	}
+}
+
+// WithAuthMode sets how the auth middlewares treat requests without valid credentials, which defaults to AuthRequired.
+func WithAuthMode(mode AuthMode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// WithTokenExtractors sets the extractors that the auth middlewares try in order to find the token of a request.
+func WithTokenExtractors(extractors ...TokenExtractor) Option {
+	return func(o *options) {
+		o.extractors = extractors
+	}
+}
+
+// WithEventHooks makes the auth middlewares pass every authentication and authorization decision to the hooks.
+func WithEventHooks(hooks ...EventHook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, hooks...)
+	}
+}
+
+// WithKeyFunc sets the key function that the auth middlewares verify tokens with against the public key of the broker,
+// which defaults to lushauth.RSAKeyFunc. e.g. lushauth.RSAPSSKeyFunc
+func WithKeyFunc(fn auth.PublicKeyFunc) Option {
+	return func(o *options) {
+		o.keyfunc = fn
+	}
+}
+
+// WithKeySet makes the auth middlewares verify tokens with the key picked from the key set by their kid header.
+// Tokens without a kid header are verified with the public key of the broker, unless the key set has a key without an ID.
+func WithKeySet(keys ...lushauth.VerificationKey) Option {
+	return func(o *options) {
+		o.keyfunc = func(pk crypto.PublicKey) jwt.Keyfunc {
+			if pk, ok := pk.(*rsa.PublicKey); ok && pk.N != nil {
+				return lushauth.KeySetFunc(append(keys[:len(keys):len(keys)], lushauth.VerificationKey{Key: pk})...)
+			}
+			return lushauth.KeySetFunc(keys...)
+		}
+	}
+}
+
+// matchRoute checks if a route matches a pattern, where a trailing * matches any route with the same prefix.
+// Routes are HTTP paths (e.g. /users/me) or full gRPC method names (e.g. /users.Users/Get).
+func matchRoute(pattern, route string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(route, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == route
+}
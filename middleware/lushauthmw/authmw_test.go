@@ -1,10 +1,15 @@
 package lushauthmw_test
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -13,9 +18,14 @@ import (
 	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
 	"github.com/LUSHDigital/core/auth"
 	"github.com/LUSHDigital/core/auth/authmock"
+	"github.com/LUSHDigital/core/test"
+	"github.com/LUSHDigital/core/workers/keybroker/keybrokermock"
 	"github.com/LUSHDigital/uuid"
 	jwt "github.com/dgrijalva/jwt-go"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -139,7 +149,7 @@ func ExampleJWTHandler() {
 	http.Handle("/users", lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {
 		consumer := lushauth.ConsumerFromContext(r.Context())
 		if !consumer.HasAnyGrant("users.read") {
-			http.Error(w, "access denied", http.StatusUnauthorized)
+			http.Error(w, "access denied", http.StatusForbidden)
 		}
 	}))
 }
@@ -148,3 +158,118 @@ func ExampleJWTMiddleware() {
 	middleware := lushauthmw.JWTMiddleware(broker)
 	router.Use(middleware)
 }
+
+var ecdsaPrivate, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+func ExampleWithKeySet() {
+	lushauthmw.JWTMiddleware(broker, lushauthmw.WithKeySet(
+		lushauth.VerificationKey{ID: "2019-10", Algorithms: []string{"ES256"}, Key: &ecdsaPrivate.PublicKey},
+	))
+}
+
+func signWithKeyID(method jwt.SigningMethod, kid string, key interface{}) string {
+	token := jwt.NewWithClaims(method, &validClaims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	return mustIssue(token.SignedString(key))
+}
+
+func TestMiddlewares_KeyFunc(t *testing.T) {
+	var (
+		es256 = signWithKeyID(jwt.SigningMethodES256, "ec", ecdsaPrivate)
+		ps256 = signWithKeyID(jwt.SigningMethodPS256, "", private)
+		hs256 = signWithKeyID(jwt.SigningMethodHS256, "", []byte("secret"))
+	)
+	keySet := lushauthmw.WithKeySet(lushauth.VerificationKey{ID: "ec", Algorithms: []string{"ES256"}, Key: &ecdsaPrivate.PublicKey})
+	cases := []struct {
+		name      string
+		opts      []lushauthmw.Option
+		token     string
+		permitted bool
+	}{
+		{
+			name:      "default key func with RSA token",
+			token:     validToken,
+			permitted: true,
+		},
+		{
+			name:  "default key func with RSA-PSS token",
+			token: ps256,
+		},
+		{
+			name:      "RSA-PSS key func with RSA-PSS token",
+			opts:      []lushauthmw.Option{lushauthmw.WithKeyFunc(lushauth.RSAPSSKeyFunc)},
+			token:     ps256,
+			permitted: true,
+		},
+		{
+			name:  "RSA-PSS key func with RSA token",
+			opts:  []lushauthmw.Option{lushauthmw.WithKeyFunc(lushauth.RSAPSSKeyFunc)},
+			token: validToken,
+		},
+		{
+			name:      "key set with ECDSA token",
+			opts:      []lushauthmw.Option{keySet},
+			token:     es256,
+			permitted: true,
+		},
+		{
+			name:      "key set with RSA token verified by the broker",
+			opts:      []lushauthmw.Option{keySet},
+			token:     validToken,
+			permitted: true,
+		},
+		{
+			name:  "key set with RSA token signed by an unknown key",
+			opts:  []lushauthmw.Option{keySet},
+			token: otherToken,
+		},
+		{
+			name:  "key set with HMAC token",
+			opts:  []lushauthmw.Option{keySet},
+			token: hs256,
+		},
+		{
+			name:  "default key func with ECDSA token",
+			token: es256,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			t.Run("http", func(t *testing.T) {
+				var consumer lushauth.Consumer
+				handler := lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {
+					consumer = lushauth.ConsumerFromContext(r.Context())
+				}, c.opts...)
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", "Bearer "+c.token)
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if c.permitted {
+					test.Equals(t, http.StatusOK, rec.Code)
+					test.Equals(t, validClaims.Consumer.UUID, consumer.UUID)
+				} else {
+					test.Equals(t, http.StatusUnauthorized, rec.Code)
+				}
+			})
+			t.Run("grpc", func(t *testing.T) {
+				md := metadata.MD{}
+				md.Set("auth-token", c.token)
+				ctx := metadata.NewIncomingContext(context.Background(), md)
+				var consumer lushauth.Consumer
+				_, err := lushauthmw.UnaryServerInterceptor(broker, c.opts...)(ctx, nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+					consumer = lushauth.ConsumerFromContext(ctx)
+					return nil, nil
+				})
+				if c.permitted {
+					test.Equals(t, nil, err)
+					test.Equals(t, validClaims.Consumer.UUID, consumer.UUID)
+				} else {
+					test.Equals(t, codes.Unauthenticated, status.Code(err))
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package lushauthmw
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"google.golang.org/grpc"
+)
+
+// UnaryClientTokenInterceptor is a gRPC client-side interceptor that authenticates unary calls with a token from the token source.
+func UnaryClientTokenInterceptor(source lushauth.TokenSource) func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		token, err := source.Token(ctx)
+		if err != nil {
+			return err
+		}
+		return invoker(ContextWithAuthTokenMetadata(ctx, token), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientTokenInterceptor is a gRPC client-side interceptor that authenticates streaming calls with a token from the token source.
+func StreamClientTokenInterceptor(source lushauth.TokenSource) func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		token, err := source.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ContextWithAuthTokenMetadata(ctx, token), desc, cc, method, opts...)
+	}
+}
+
+// PerRPCCredentials authenticates gRPC calls with a token from the token source.
+// It implements the credentials.PerRPCCredentials interface and can be used with grpc.WithPerRPCCredentials.
+type PerRPCCredentials struct {
+	Source lushauth.TokenSource
+	// AllowInsecure allows the token to be sent over connections without transport security.
+	AllowInsecure bool
+}
+
+// GetRequestMetadata gets a token from the token source and returns it as request metadata.
+func (c PerRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.Source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{metaAuthTokenKey: token}, nil
+}
+
+// RequireTransportSecurity indicates whether the credentials require transport security.
+func (c PerRPCCredentials) RequireTransportSecurity() bool {
+	return !c.AllowInsecure
+}
+
+// Transport is an http.RoundTripper that authenticates requests with a bearer token from the token source.
+type Transport struct {
+	Source lushauth.TokenSource
+	// Base is the round tripper used to make the requests, which defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+// RoundTrip authenticates the request and makes it with the base round tripper.
+// The request is cloned rather than modified, as required by the http.RoundTripper interface.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := t.Source.Token(r.Context())
+	if err != nil {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set(authHeader, authHeaderPrefix+token)
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r)
+}
@@ -0,0 +1,74 @@
+package lushauthmw_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/LUSHDigital/core/test"
+	"github.com/LUSHDigital/core/workers/keybroker/keybrokermock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func ExampleTransport() {
+	source := lushauth.NewCachingTokenSource(lushauth.NewServiceIssuer(issuer, "stock-sync", lushauth.Service{
+		ClientID: "stock-sync",
+		Scopes:   []string{"stock.update"},
+	}))
+	client := &http.Client{Transport: &lushauthmw.Transport{Source: source}}
+	client.Get("https://stock.example.com/levels")
+}
+
+func ExamplePerRPCCredentials() {
+	source := lushauth.NewCachingTokenSource(lushauth.NewServiceIssuer(issuer, "stock-sync", lushauth.Service{
+		ClientID: "stock-sync",
+		Scopes:   []string{"stock.update"},
+	}))
+	grpc.Dial("stock:50051", grpc.WithPerRPCCredentials(lushauthmw.PerRPCCredentials{Source: source}))
+}
+
+func TestTransport(t *testing.T) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	var consumer lushauth.Consumer
+	srv := httptest.NewServer(lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {
+		consumer = lushauth.ConsumerFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &lushauthmw.Transport{Source: lushauth.StaticTokenSource(validToken)}}
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Equals(t, validClaims.Consumer, consumer)
+	test.Equals(t, "", req.Header.Get("Authorization"))
+}
+
+func TestPerRPCCredentials(t *testing.T) {
+	creds := lushauthmw.PerRPCCredentials{Source: lushauth.StaticTokenSource(validToken)}
+	md, err := creds.GetRequestMetadata(context.Background())
+	test.Equals(t, nil, err)
+	test.Equals(t, map[string]string{"auth-token": validToken}, md)
+	test.Equals(t, true, creds.RequireTransportSecurity())
+}
+
+func TestUnaryClientTokenInterceptor(t *testing.T) {
+	mw := lushauthmw.UnaryClientTokenInterceptor(lushauth.StaticTokenSource(validToken))
+	err := mw(context.Background(), "/users.Users/Get", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		test.Equals(t, []string{validToken}, md.Get("auth-token"))
+		return nil
+	})
+	test.Equals(t, nil, err)
+}
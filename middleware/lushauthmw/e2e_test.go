@@ -0,0 +1,230 @@
+package lushauthmw_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/LUSHDigital/core/test"
+	"github.com/LUSHDigital/core/workers/keybroker/keybrokermock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// consumerHealthServer reports that it is serving only to the expected consumer.
+type consumerHealthServer struct {
+	uuid string
+}
+
+func (s consumerHealthServer) status(ctx context.Context) (*healthpb.HealthCheckResponse, error) {
+	if consumer := lushauth.ConsumerFromContext(ctx); consumer.UUID != s.uuid {
+		return nil, status.Errorf(codes.PermissionDenied, "unexpected consumer %q", consumer.UUID)
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (s consumerHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	return s.status(ctx)
+}
+
+func (s consumerHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	res, err := s.status(stream.Context())
+	if err != nil {
+		return err
+	}
+	return stream.Send(res)
+}
+
+// dialHealthServer starts a health server behind the auth interceptors on an in-memory connection and dials it.
+// The returned function closes the connection and stops the server.
+func dialHealthServer(t *testing.T, opts ...grpc.DialOption) (healthpb.HealthClient, func()) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	return serveHealth(t, consumerHealthServer{uuid: validClaims.Consumer.UUID}, []grpc.ServerOption{
+		lushauthmw.NewUnaryServerInterceptor(broker),
+		lushauthmw.NewStreamServerInterceptor(broker),
+	}, opts...)
+}
+
+// serveHealth starts a health server with the server options on an in-memory connection and dials it.
+// The returned function closes the connection and stops the server.
+func serveHealth(t *testing.T, health healthpb.HealthServer, serverOpts []grpc.ServerOption, opts ...grpc.DialOption) (healthpb.HealthClient, func()) {
+	l := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(serverOpts...)
+	healthpb.RegisterHealthServer(srv, health)
+	go srv.Serve(l)
+	opts = append(opts,
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return l.Dial()
+		}),
+	)
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return healthpb.NewHealthClient(conn), func() {
+		conn.Close()
+		srv.Stop()
+	}
+}
+
+func TestEndToEnd_Unary(t *testing.T) {
+	cases := []struct {
+		name         string
+		opts         []grpc.DialOption
+		expectedCode codes.Code
+	}{
+		{
+			name:         "client interceptor",
+			opts:         []grpc.DialOption{grpc.WithUnaryInterceptor(lushauthmw.UnaryClientInterceptor(validToken))},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "client token interceptor",
+			opts:         []grpc.DialOption{grpc.WithUnaryInterceptor(lushauthmw.UnaryClientTokenInterceptor(lushauth.StaticTokenSource(validToken)))},
+			expectedCode: codes.OK,
+		},
+		{
+			name: "per rpc credentials",
+			opts: []grpc.DialOption{grpc.WithPerRPCCredentials(lushauthmw.PerRPCCredentials{
+				Source:        lushauth.StaticTokenSource(validToken),
+				AllowInsecure: true,
+			})},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "expired token",
+			opts:         []grpc.DialOption{grpc.WithUnaryInterceptor(lushauthmw.UnaryClientInterceptor(expiredToken))},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "no client interceptor",
+			expectedCode: codes.Unauthenticated,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, stop := dialHealthServer(t, c.opts...)
+			defer stop()
+			res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			test.Equals(t, c.expectedCode, status.Code(err))
+			if c.expectedCode == codes.OK {
+				test.Equals(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+			}
+		})
+	}
+}
+
+func TestEndToEnd_Stream(t *testing.T) {
+	cases := []struct {
+		name         string
+		opts         []grpc.DialOption
+		expectedCode codes.Code
+	}{
+		{
+			name:         "client interceptor",
+			opts:         []grpc.DialOption{grpc.WithStreamInterceptor(lushauthmw.StreamClientInterceptor(validToken))},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "client token interceptor",
+			opts:         []grpc.DialOption{grpc.WithStreamInterceptor(lushauthmw.StreamClientTokenInterceptor(lushauth.StaticTokenSource(validToken)))},
+			expectedCode: codes.OK,
+		},
+		{
+			name: "per rpc credentials",
+			opts: []grpc.DialOption{grpc.WithPerRPCCredentials(lushauthmw.PerRPCCredentials{
+				Source:        lushauth.StaticTokenSource(validToken),
+				AllowInsecure: true,
+			})},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "expired token",
+			opts:         []grpc.DialOption{grpc.WithStreamInterceptor(lushauthmw.StreamClientInterceptor(expiredToken))},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "no client interceptor",
+			expectedCode: codes.Unauthenticated,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, stop := dialHealthServer(t, c.opts...)
+			defer stop()
+			stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := stream.Recv()
+			test.Equals(t, c.expectedCode, status.Code(err))
+			if c.expectedCode == codes.OK {
+				test.Equals(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+			}
+		})
+	}
+}
+
+func TestEndToEnd_StreamPolicy(t *testing.T) {
+	const watch = "/grpc.health.v1.Health/Watch"
+	cases := []struct {
+		name         string
+		policy       lushauth.Permitter
+		token        string
+		uuid         string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "permitted call",
+			policy:       lushauth.UserPolicy{validClaims.Consumer.UUID},
+			token:        validToken,
+			uuid:         validClaims.Consumer.UUID,
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "denied call",
+			policy:       lushauth.RolePolicy{"admin"},
+			token:        validToken,
+			uuid:         validClaims.Consumer.UUID,
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "call without token",
+			policy:       lushauth.UserPolicy{validClaims.Consumer.UUID},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "public method without token",
+			policy:       lushauthmw.PublicMethod,
+			expectedCode: codes.OK,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			var opts []grpc.DialOption
+			if c.token != "" {
+				opts = append(opts, grpc.WithStreamInterceptor(lushauthmw.StreamClientInterceptor(c.token)))
+			}
+			client, stop := serveHealth(t, consumerHealthServer{uuid: c.uuid}, []grpc.ServerOption{
+				lushauthmw.NewStreamServerPolicyInterceptor(broker, lushauthmw.MethodPolicies{watch: c.policy}),
+			}, opts...)
+			defer stop()
+			stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := stream.Recv()
+			test.Equals(t, c.expectedCode, status.Code(err))
+			if c.expectedCode == codes.OK {
+				test.Equals(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package lushauthmw
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Outcome is the outcome of an authentication or authorization decision.
+type Outcome string
+
+const (
+	// OutcomeAuthenticated happens when a request was authenticated with a valid token.
+	OutcomeAuthenticated Outcome = "authenticated"
+	// OutcomeAnonymous happens when a request without valid credentials continued as the anonymous consumer.
+	OutcomeAnonymous Outcome = "anonymous"
+	// OutcomeUnauthenticated happens when a request was refused for missing or invalid credentials.
+	OutcomeUnauthenticated Outcome = "unauthenticated"
+	// OutcomeUnavailable happens when a request was refused because its token could not be checked.
+	OutcomeUnavailable Outcome = "unavailable"
+	// OutcomePermitted happens when a consumer was permitted access.
+	OutcomePermitted Outcome = "permitted"
+	// OutcomeDenied happens when a consumer was denied access.
+	OutcomeDenied Outcome = "denied"
+)
+
+// Event describes an authentication or authorization decision made by the auth middlewares.
+type Event struct {
+	// Route is the HTTP path or full gRPC method name of the request.
+	Route string `json:"route"`
+	// Outcome is the outcome of the decision.
+	Outcome Outcome `json:"outcome"`
+	// Reason explains why access was refused or denied.
+	Reason string `json:"reason,omitempty"`
+	// ConsumerUUID is the UUID of the consumer, if known.
+	ConsumerUUID string `json:"consumer_uuid,omitempty"`
+	// TokenID is the id (JTI) of the token, if known.
+	TokenID string `json:"jti,omitempty"`
+}
+
+// EventHook defines the behavior of receiving the decisions made by the auth middlewares.
+// Hooks are called synchronously for every request, so they should not block.
+type EventHook interface {
+	AuthEvent(ctx context.Context, e Event)
+}
+
+// EventHookFunc allows a function to be used as an event hook.
+type EventHookFunc func(ctx context.Context, e Event)
+
+// AuthEvent receives an event.
+func (f EventHookFunc) AuthEvent(ctx context.Context, e Event) {
+	f(ctx, e)
+}
+
+// EventHooks passes events to every one of the hooks.
+type EventHooks []EventHook
+
+// AuthEvent receives an event.
+func (hooks EventHooks) AuthEvent(ctx context.Context, e Event) {
+	for _, hook := range hooks {
+		hook.AuthEvent(ctx, e)
+	}
+}
+
+// authenticationEvent creates an event for authenticating a request, where the error is the reason it failed.
+func authenticationEvent(route string, claims lushauth.Claims, err error) Event {
+	e := Event{
+		Route:        route,
+		Outcome:      OutcomeAuthenticated,
+		ConsumerUUID: claims.Consumer.UUID,
+		TokenID:      claims.ID,
+	}
+	switch {
+	case err != nil:
+		e.Outcome, e.Reason = OutcomeUnauthenticated, status.Convert(err).Message()
+		if _, ok := err.(revocationUnavailableError); ok || status.Code(err) == codes.Unavailable {
+			e.Outcome = OutcomeUnavailable
+		}
+	case claims.Consumer.IsAnonymous():
+		e.Outcome = OutcomeAnonymous
+	}
+	return e
+}
+
+// authorizationEvent creates an event for authorizing a consumer, where the error is the reason access was denied.
+func authorizationEvent(ctx context.Context, route string, err error) Event {
+	e := Event{
+		Route:        route,
+		Outcome:      OutcomePermitted,
+		ConsumerUUID: lushauth.ConsumerFromContext(ctx).UUID,
+		TokenID:      tokenIDFromContext(ctx),
+	}
+	if err != nil {
+		e.Outcome, e.Reason = OutcomeDenied, status.Convert(err).Message()
+	}
+	return e
+}
+
+// AuditLog writes every decision made by the auth middlewares as a line of JSON, including the full route.
+type AuditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewAuditLog creates an audit log writing to a writer.
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w}
+}
+
+type auditRecord struct {
+	Time time.Time `json:"time"`
+	Event
+}
+
+// AuthEvent writes an event.
+func (l *AuditLog) AuthEvent(ctx context.Context, e Event) {
+	b, err := json.Marshal(auditRecord{Time: lushauth.TimeFunc().UTC(), Event: e})
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(b, '\n'))
+}
@@ -0,0 +1,172 @@
+package lushauthmw_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/LUSHDigital/core/test"
+	"github.com/LUSHDigital/core/workers/keybroker/keybrokermock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type eventRecorder struct {
+	events []lushauthmw.Event
+}
+
+func (r *eventRecorder) AuthEvent(ctx context.Context, e lushauthmw.Event) {
+	r.events = append(r.events, e)
+}
+
+func ExampleWithEventHooks() {
+	hooks := lushauthmw.WithEventHooks(lushauthmw.NewAuditLog(&bytes.Buffer{}))
+	lushauthmw.NewUnaryServerInterceptor(broker, hooks)
+}
+
+func TestJWTHandler_Events(t *testing.T) {
+	cases := []struct {
+		name     string
+		token    string
+		mode     lushauthmw.AuthMode
+		expected lushauthmw.Event
+	}{
+		{
+			name:  "token is good",
+			token: validToken,
+			expected: lushauthmw.Event{
+				Route:        "/orders",
+				Outcome:      lushauthmw.OutcomeAuthenticated,
+				ConsumerUUID: validClaims.Consumer.UUID,
+				TokenID:      validClaims.ID,
+			},
+		},
+		{
+			name: "token is missing",
+			expected: lushauthmw.Event{
+				Route:   "/orders",
+				Outcome: lushauthmw.OutcomeUnauthenticated,
+				Reason:  "missing bearer token",
+			},
+		},
+		{
+			name: "token is missing in optional mode",
+			mode: lushauthmw.AuthOptional,
+			expected: lushauthmw.Event{
+				Route:        "/orders",
+				Outcome:      lushauthmw.OutcomeAnonymous,
+				ConsumerUUID: lushauth.AnonymousUUID,
+			},
+		},
+		{
+			name:  "token has expired",
+			token: expiredToken,
+			expected: lushauthmw.Event{
+				Route:   "/orders",
+				Outcome: lushauthmw.OutcomeUnauthenticated,
+				Reason:  "could not verify token: has expired",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			recorder := &eventRecorder{}
+			handler := lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {}, lushauthmw.WithEventHooks(recorder), lushauthmw.WithAuthMode(c.mode))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			test.Equals(t, []lushauthmw.Event{c.expected}, recorder.events)
+		})
+	}
+}
+
+func TestHandlerPolicy_Events(t *testing.T) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	recorder := &eventRecorder{}
+	hooks := lushauthmw.WithEventHooks(recorder)
+	next := func(w http.ResponseWriter, r *http.Request) {}
+	handler := lushauthmw.JWTHandler(broker, lushauthmw.HandlerPolicy(lushauth.RolePolicy{"admin"}, next, hooks), hooks)
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+validToken)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	test.Equals(t, []lushauthmw.Event{
+		{
+			Route:        "/orders",
+			Outcome:      lushauthmw.OutcomeAuthenticated,
+			ConsumerUUID: validClaims.Consumer.UUID,
+			TokenID:      validClaims.ID,
+		},
+		{
+			Route:        "/orders",
+			Outcome:      lushauthmw.OutcomeDenied,
+			Reason:       lushauth.RolePolicy{"admin"}.Error(),
+			ConsumerUUID: validClaims.Consumer.UUID,
+			TokenID:      validClaims.ID,
+		},
+	}, recorder.events)
+}
+
+func TestUnaryServerPolicyInterceptor_Events(t *testing.T) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	recorder := &eventRecorder{}
+	policies := lushauthmw.MethodPolicies{
+		"/orders.Orders/Get":    lushauthmw.PublicMethod,
+		"/orders.Orders/Delete": lushauth.RolePolicy{"admin"},
+	}
+	mw := lushauthmw.UnaryServerPolicyInterceptor(broker, policies, lushauthmw.WithEventHooks(recorder))
+	md := metadata.MD{}
+	md.Set("auth-token", validToken)
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	mw(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/orders.Orders/Get"}, ok)
+	mw(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/orders.Orders/Delete"}, ok)
+
+	outcomes := make([]lushauthmw.Outcome, 0, len(recorder.events))
+	for _, e := range recorder.events {
+		outcomes = append(outcomes, e.Outcome)
+	}
+	test.Equals(t, []lushauthmw.Outcome{
+		lushauthmw.OutcomePermitted,
+		lushauthmw.OutcomeAuthenticated,
+		lushauthmw.OutcomeDenied,
+	}, outcomes)
+	test.Equals(t, "/orders.Orders/Delete", recorder.events[2].Route)
+	test.Equals(t, validClaims.Consumer.UUID, recorder.events[2].ConsumerUUID)
+	test.Equals(t, validClaims.ID, recorder.events[2].TokenID)
+}
+
+func TestAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	audit := lushauthmw.NewAuditLog(&buf)
+	audit.AuthEvent(context.Background(), lushauthmw.Event{
+		Route:        "/orders",
+		Outcome:      lushauthmw.OutcomeDenied,
+		Reason:       "missing required roles",
+		ConsumerUUID: validClaims.Consumer.UUID,
+		TokenID:      validClaims.ID,
+	})
+	audit.AuthEvent(context.Background(), lushauthmw.Event{Route: "/orders", Outcome: lushauthmw.OutcomeUnauthenticated})
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	test.Equals(t, 2, len(lines))
+	var record map[string]interface{}
+	if err := json.Unmarshal(lines[0], &record); err != nil {
+		t.Fatal(err)
+	}
+	test.Equals(t, map[string]interface{}{
+		"time":          now.UTC().Format("2006-01-02T15:04:05.999999999Z07:00"),
+		"route":         "/orders",
+		"outcome":       "denied",
+		"reason":        "missing required roles",
+		"consumer_uuid": validClaims.Consumer.UUID,
+		"jti":           validClaims.ID,
+	}, record)
+}
@@ -0,0 +1,124 @@
+package lushauthmw
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// TokenExtractor defines the behavior of extracting a raw token from an HTTP request or gRPC metadata.
+// Extractors report whether they found a token, so that the next extractor can be tried when they did not.
+type TokenExtractor interface {
+	FromRequest(r *http.Request) (string, bool)
+	FromMetadata(md metadata.MD) (string, bool)
+}
+
+// DefaultTokenExtractors are used by the auth middlewares unless other extractors are given with WithTokenExtractors.
+var DefaultTokenExtractors = []TokenExtractor{
+	MetadataExtractor{Key: metaAuthTokenKey},
+	HeaderExtractor{Name: authHeader, Prefix: authHeaderPrefix},
+}
+
+// HeaderExtractor extracts a token from a header, where the header needs to start with the prefix.
+// In gRPC metadata the header is read from the lower case key, e.g. "authorization".
+type HeaderExtractor struct {
+	Name   string
+	Prefix string
+}
+
+// FromRequest extracts the token from the request header.
+func (e HeaderExtractor) FromRequest(r *http.Request) (string, bool) {
+	return e.extract(r.Header.Get(e.Name))
+}
+
+// FromMetadata extracts the token from the metadata key named after the header.
+func (e HeaderExtractor) FromMetadata(md metadata.MD) (string, bool) {
+	values := md.Get(e.Name)
+	if len(values) < 1 {
+		return "", false
+	}
+	return e.extract(values[0])
+}
+
+func (e HeaderExtractor) extract(value string) (string, bool) {
+	if value == "" || !strings.HasPrefix(value, e.Prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, e.Prefix), true
+}
+
+// CookieExtractor extracts a token from an HTTP cookie.
+type CookieExtractor struct {
+	Name string
+}
+
+// FromRequest extracts the token from the request cookie.
+func (e CookieExtractor) FromRequest(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(e.Name)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// FromMetadata never finds a token, since there are no cookies in gRPC metadata.
+func (e CookieExtractor) FromMetadata(md metadata.MD) (string, bool) {
+	return "", false
+}
+
+// QueryExtractor extracts a token from a URL query parameter.
+// e.g. for websocket upgrades where headers can not be set
+type QueryExtractor struct {
+	Name string
+}
+
+// FromRequest extracts the token from the request query parameter.
+func (e QueryExtractor) FromRequest(r *http.Request) (string, bool) {
+	value := r.URL.Query().Get(e.Name)
+	return value, value != ""
+}
+
+// FromMetadata never finds a token, since there is no query in gRPC metadata.
+func (e QueryExtractor) FromMetadata(md metadata.MD) (string, bool) {
+	return "", false
+}
+
+// MetadataExtractor extracts a token from a gRPC metadata key as is.
+type MetadataExtractor struct {
+	Key string
+}
+
+// FromRequest never finds a token, since there is no gRPC metadata in HTTP requests.
+func (e MetadataExtractor) FromRequest(r *http.Request) (string, bool) {
+	return "", false
+}
+
+// FromMetadata extracts the token from the metadata key.
+func (e MetadataExtractor) FromMetadata(md metadata.MD) (string, bool) {
+	values := md.Get(e.Key)
+	if len(values) < 1 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// tokenFromRequest extracts a token from the request with the first extractor to find one.
+func tokenFromRequest(r *http.Request, extractors []TokenExtractor) (string, bool) {
+	for _, e := range extractors {
+		if token, ok := e.FromRequest(r); ok {
+			return token, true
+		}
+	}
+	return "", false
+}
+
+// tokenFromMetadata extracts a token from the metadata with the first extractor to find one.
+func tokenFromMetadata(md metadata.MD, extractors []TokenExtractor) (string, bool) {
+	for _, e := range extractors {
+		if token, ok := e.FromMetadata(md); ok {
+			return token, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,117 @@
+package lushauthmw_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/LUSHDigital/core/test"
+	"github.com/LUSHDigital/core/workers/keybroker/keybrokermock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var extractors = []lushauthmw.TokenExtractor{
+	lushauthmw.HeaderExtractor{Name: "Authorization", Prefix: "Bearer "},
+	lushauthmw.CookieExtractor{Name: "session"},
+	lushauthmw.QueryExtractor{Name: "access_token"},
+	lushauthmw.MetadataExtractor{Key: "auth-token"},
+}
+
+func ExampleWithTokenExtractors() {
+	router.Use(lushauthmw.JWTMiddleware(broker, lushauthmw.WithTokenExtractors(
+		lushauthmw.HeaderExtractor{Name: "Authorization", Prefix: "Bearer "},
+		lushauthmw.CookieExtractor{Name: "session"},
+	)))
+}
+
+func TestJWTHandler_TokenExtractors(t *testing.T) {
+	cases := []struct {
+		name               string
+		request            func(r *http.Request)
+		expectedStatusCode int
+	}{
+		{
+			name: "token in header",
+			request: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+validToken)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "token in cookie",
+			request: func(r *http.Request) {
+				r.AddCookie(&http.Cookie{Name: "session", Value: validToken})
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "token in query",
+			request: func(r *http.Request) {
+				r.URL.RawQuery = "access_token=" + validToken
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "invalid token in header takes precedence",
+			request: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+expiredToken)
+				r.AddCookie(&http.Cookie{Name: "session", Value: validToken})
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "token is missing",
+			request:            func(r *http.Request) {},
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			c.request(req)
+			recorder := httptest.NewRecorder()
+			handler := lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}, lushauthmw.WithTokenExtractors(extractors...))
+			handler.ServeHTTP(recorder, req)
+			test.Equals(t, c.expectedStatusCode, recorder.Code)
+		})
+	}
+}
+
+func TestInterceptServerJWT_TokenExtractors(t *testing.T) {
+	cases := []struct {
+		name         string
+		md           metadata.MD
+		expectedCode codes.Code
+	}{
+		{
+			name:         "token in auth-token",
+			md:           metadata.Pairs("auth-token", validToken),
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "token in authorization",
+			md:           metadata.Pairs("authorization", "Bearer "+validToken),
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "authorization without bearer prefix",
+			md:           metadata.Pairs("authorization", validToken),
+			expectedCode: codes.Unauthenticated,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			ctx := metadata.NewIncomingContext(context.Background(), c.md)
+			_, err := lushauthmw.InterceptServerJWT(ctx, broker)
+			test.Equals(t, c.expectedCode, status.Code(err))
+		})
+	}
+}
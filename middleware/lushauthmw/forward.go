@@ -0,0 +1,136 @@
+package lushauthmw
+
+import (
+	"context"
+	"errors"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"google.golang.org/grpc"
+)
+
+type key int
+
+const (
+	tokenKey key = iota
+	tokenIDKey
+)
+
+// ErrNoConsumerToForward happens when an act-as token is requested outside of an authenticated call.
+var ErrNoConsumerToForward = errors.New("no authenticated consumer to act on behalf of")
+
+// ContextWithToken takes a context and a raw token and returns a new context with the token embedded.
+func ContextWithToken(parent context.Context, token string) context.Context {
+	return context.WithValue(parent, tokenKey, token)
+}
+
+// TokenFromContext extracts the raw token that authenticated the incoming call from the supplied context.
+func TokenFromContext(ctx context.Context) (string, bool) {
+	t, ok := ctx.Value(tokenKey).(string)
+	return t, ok
+}
+
+// contextWithAuth embeds the claims and, when there is one, the raw token of an authenticated call in a context.
+func contextWithAuth(parent context.Context, claims lushauth.Claims, raw string) context.Context {
+	ctx := lushauth.ContextWithClaims(parent, claims)
+	if raw != "" {
+		ctx = ContextWithToken(ctx, raw)
+		ctx = context.WithValue(ctx, tokenIDKey, claims.ID)
+	}
+	return ctx
+}
+
+// tokenIDFromContext extracts the id (JTI) of the token that authenticated the incoming call from the supplied context.
+func tokenIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tokenIDKey).(string)
+	return id
+}
+
+// IncomingTokenSource is a token source that returns the token of the incoming call, or no token when there is none.
+var IncomingTokenSource lushauth.TokenSource = lushauth.TokenSourceFunc(func(ctx context.Context) (string, error) {
+	token, _ := TokenFromContext(ctx)
+	return token, nil
+})
+
+// ActAsTokenSource returns a token source that issues tokens for the consumer of the incoming call with the service acting on their behalf.
+func ActAsTokenSource(issuer lushauth.TokenIssuer, name string, service lushauth.Service) lushauth.TokenSource {
+	return lushauth.TokenSourceFunc(func(ctx context.Context) (string, error) {
+		consumer := lushauth.ConsumerFromContext(ctx)
+		if consumer.UUID == "" || consumer.IsAnonymous() {
+			return "", ErrNoConsumerToForward
+		}
+		var actor *lushauth.Actor
+		if a, ok := lushauth.ActorFromContext(ctx); ok {
+			actor = &a
+		}
+		claims, err := lushauth.NewClaimsForDelegation(name, service, consumer, actor)
+		if err != nil {
+			return "", err
+		}
+		return issuer.Issue(&claims)
+	})
+}
+
+// ForwardOption represents behaviour for applying options to the forwarding client interceptors.
+type ForwardOption func(*forwardOptions)
+
+type forwardOptions struct {
+	source lushauth.TokenSource
+}
+
+func newForwardOptions(opts ...ForwardOption) *forwardOptions {
+	o := &forwardOptions{source: IncomingTokenSource}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ForwardServiceToken makes the forwarding client interceptors authenticate as a service instead of forwarding the incoming token.
+func ForwardServiceToken(source lushauth.TokenSource) ForwardOption {
+	return func(o *forwardOptions) {
+		o.source = source
+	}
+}
+
+// ForwardActAsToken makes the forwarding client interceptors authenticate with a token for the consumer of the
+// incoming call with the service acting on their behalf, instead of forwarding the incoming token.
+func ForwardActAsToken(issuer lushauth.TokenIssuer, name string, service lushauth.Service) ForwardOption {
+	return func(o *forwardOptions) {
+		o.source = ActAsTokenSource(issuer, name, service)
+	}
+}
+
+// forward adds the token to forward to the outgoing metadata of the context.
+func (o *forwardOptions) forward(ctx context.Context) (context.Context, error) {
+	token, err := o.source.Token(ctx)
+	if err != nil || token == "" {
+		return ctx, err
+	}
+	return ContextWithAuthTokenMetadata(ctx, token), nil
+}
+
+// UnaryClientForwardInterceptor is a gRPC client-side interceptor that forwards the token of the incoming call to unary calls.
+// It needs the context of the incoming call to be passed on to the outgoing call.
+func UnaryClientForwardInterceptor(opts ...ForwardOption) func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	o := newForwardOptions(opts...)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := o.forward(ctx)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientForwardInterceptor is a gRPC client-side interceptor that forwards the token of the incoming call to streaming calls.
+// It needs the context of the incoming call to be passed on to the outgoing call.
+func StreamClientForwardInterceptor(opts ...ForwardOption) func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	o := newForwardOptions(opts...)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := o.forward(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
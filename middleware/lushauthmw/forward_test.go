@@ -0,0 +1,125 @@
+package lushauthmw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/LUSHDigital/core/test"
+	"github.com/LUSHDigital/core/workers/keybroker/keybrokermock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var stockSync = lushauth.Service{ClientID: "stock-sync", Scopes: []string{"stock.update"}}
+
+func ExampleUnaryClientForwardInterceptor() {
+	grpc.Dial("stock:50051", grpc.WithUnaryInterceptor(lushauthmw.UnaryClientForwardInterceptor(
+		lushauthmw.ForwardActAsToken(issuer, "orders", lushauth.Service{ClientID: "orders"}),
+	)))
+}
+
+// forwarded calls the unary client interceptor from within a call authenticated by the unary server interceptor
+// and returns the token of the outgoing call.
+func forwarded(t *testing.T, token string, opts ...lushauthmw.ForwardOption) (string, error) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	md := metadata.MD{}
+	if token != "" {
+		md.Set("auth-token", token)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	server := lushauthmw.UnaryServerInterceptor(broker, lushauthmw.WithAuthMode(lushauthmw.AuthOptional))
+	client := lushauthmw.UnaryClientForwardInterceptor(opts...)
+	var outgoing string
+	_, err := server(ctx, nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, client(ctx, "/stock.Stock/Update", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			if tokens := md.Get("auth-token"); len(tokens) > 0 {
+				outgoing = tokens[0]
+			}
+			return nil
+		})
+	})
+	return outgoing, err
+}
+
+func TestUnaryClientForwardInterceptor(t *testing.T) {
+	t.Run("forwards the incoming token", func(t *testing.T) {
+		outgoing, err := forwarded(t, validToken)
+		test.Equals(t, nil, err)
+		test.Equals(t, validToken, outgoing)
+	})
+	t.Run("forwards no token without an incoming token", func(t *testing.T) {
+		outgoing, err := forwarded(t, "")
+		test.Equals(t, nil, err)
+		test.Equals(t, "", outgoing)
+	})
+	t.Run("uses the service token instead", func(t *testing.T) {
+		outgoing, err := forwarded(t, validToken, lushauthmw.ForwardServiceToken(lushauth.StaticTokenSource(otherToken)))
+		test.Equals(t, nil, err)
+		test.Equals(t, otherToken, outgoing)
+	})
+	t.Run("uses an act-as token instead", func(t *testing.T) {
+		outgoing, err := forwarded(t, validToken, lushauthmw.ForwardActAsToken(issuer, "Test", stockSync))
+		test.Equals(t, nil, err)
+		var claims lushauth.Claims
+		test.Equals(t, nil, parser.Parse(outgoing, &claims))
+		test.Equals(t, validClaims.Consumer, claims.Consumer)
+		test.Equals(t, &lushauth.Actor{Subject: stockSync.ClientID, Service: &stockSync}, claims.Actor)
+	})
+	t.Run("can not act on behalf of the anonymous consumer", func(t *testing.T) {
+		_, err := forwarded(t, "", lushauthmw.ForwardActAsToken(issuer, "Test", stockSync))
+		test.Equals(t, lushauthmw.ErrNoConsumerToForward, err)
+	})
+}
+
+// forwardedStream opens a stream with the stream client interceptor from within a stream authenticated by the stream
+// server interceptor and returns the token of the outgoing stream.
+func forwardedStream(t *testing.T, token string, opts ...lushauthmw.ForwardOption) (string, error) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	md := metadata.MD{}
+	if token != "" {
+		md.Set("auth-token", token)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	server := lushauthmw.StreamServerInterceptor(broker, lushauthmw.WithAuthMode(lushauthmw.AuthOptional))
+	client := lushauthmw.StreamClientForwardInterceptor(opts...)
+	var outgoing string
+	err := server(nil, contextServerStream{ctx: ctx}, &grpc.StreamServerInfo{}, func(srv interface{}, ss grpc.ServerStream) error {
+		_, err := client(ss.Context(), &grpc.StreamDesc{}, nil, "/stock.Stock/Watch", func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			if tokens := md.Get("auth-token"); len(tokens) > 0 {
+				outgoing = tokens[0]
+			}
+			return nil, nil
+		})
+		return err
+	})
+	return outgoing, err
+}
+
+func TestStreamClientForwardInterceptor(t *testing.T) {
+	t.Run("forwards the incoming token", func(t *testing.T) {
+		outgoing, err := forwardedStream(t, validToken)
+		test.Equals(t, nil, err)
+		test.Equals(t, validToken, outgoing)
+	})
+	t.Run("forwards no token without an incoming token", func(t *testing.T) {
+		outgoing, err := forwardedStream(t, "")
+		test.Equals(t, nil, err)
+		test.Equals(t, "", outgoing)
+	})
+	t.Run("uses an act-as token instead", func(t *testing.T) {
+		outgoing, err := forwardedStream(t, validToken, lushauthmw.ForwardActAsToken(issuer, "Test", stockSync))
+		test.Equals(t, nil, err)
+		var claims lushauth.Claims
+		test.Equals(t, nil, parser.Parse(outgoing, &claims))
+		test.Equals(t, validClaims.Consumer, claims.Consumer)
+		test.Equals(t, &lushauth.Actor{Subject: stockSync.ClientID, Service: &stockSync}, claims.Actor)
+	})
+	t.Run("can not act on behalf of the anonymous consumer", func(t *testing.T) {
+		_, err := forwardedStream(t, "", lushauthmw.ForwardActAsToken(issuer, "Test", stockSync))
+		test.Equals(t, lushauthmw.ErrNoConsumerToForward, err)
+	})
+}
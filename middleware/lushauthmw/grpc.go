@@ -6,7 +6,6 @@ import (
 	"log"
 
 	"github.com/LUSHDigital/core-lush/lushauth"
-	"github.com/LUSHDigital/core/auth"
 	"github.com/dgrijalva/jwt-go"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -19,21 +18,22 @@ const (
 )
 
 var (
-	// ErrMetadataMissing happens when there is no metadata with the request
-	ErrMetadataMissing = status.Error(codes.InvalidArgument, "metadata missing")
+	// ErrMetadataMissing happens when there is no metadata with the request.
+	// Calls without metadata are refused unless the auth mode is AuthOptional or AuthAnonymous.
+	ErrMetadataMissing = status.Error(codes.Unauthenticated, "metadata missing")
 
 	// ErrAuthTokenMissing happens when there is no auth token in the metadata
-	ErrAuthTokenMissing = status.Error(codes.InvalidArgument, "metadata missing: auth-token")
+	ErrAuthTokenMissing = status.Error(codes.Unauthenticated, "metadata missing: auth-token")
 )
 
 // NewStreamServerInterceptor creates a grpc server option with your key broker.
-func NewStreamServerInterceptor(broker CopierRenewer) grpc.ServerOption {
-	return grpc.StreamInterceptor(StreamServerInterceptor(broker))
+func NewStreamServerInterceptor(broker CopierRenewer, opts ...Option) grpc.ServerOption {
+	return grpc.StreamInterceptor(StreamServerInterceptor(broker, opts...))
 }
 
 // NewUnaryServerInterceptor creates a unary grpc server option with your key broker.
-func NewUnaryServerInterceptor(broker CopierRenewer) grpc.ServerOption {
-	return grpc.UnaryInterceptor(UnaryServerInterceptor(broker))
+func NewUnaryServerInterceptor(broker CopierRenewer, opts ...Option) grpc.ServerOption {
+	return grpc.UnaryInterceptor(UnaryServerInterceptor(broker, opts...))
 }
 
 // ContextWithAuthTokenMetadata will add a JWT to the client outgoing context metadata
@@ -51,101 +51,118 @@ func UnaryClientInterceptor(jwt string) func(ctx context.Context, method string,
 }
 
 // StreamClientInterceptor is a gRPC client-side interceptor that provides Prometheus monitoring for Streaming RPCs.
+// The JWT is added to the metadata before the stream is opened, so that it is sent along with the headers of the stream.
 func StreamClientInterceptor(jwt string) func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-		clientStream, err := streamer(ctx, desc, cc, method, opts...)
-		if err != nil {
-			return nil, err
-		}
-		return &authenticatedClientStream{clientStream, jwt}, nil
+		ctx = ContextWithAuthTokenMetadata(ctx, jwt)
+		return streamer(ctx, desc, cc, method, opts...)
 	}
 }
 
-type authenticatedClientStream struct {
-	grpc.ClientStream
-	jwt string
+// InterceptServerJWT will check the context metadata for a JWT.
+// It is called for every request, so the public key is only renewed when the broker is a RenewalCoordinator
+// that rate limits the renewals across requests.
+func InterceptServerJWT(ctx context.Context, broker CopierRenewer, opts ...Option) (lushauth.Consumer, error) {
+	if _, ok := broker.(*RenewalCoordinator); !ok {
+		broker = withoutRenewal{broker}
+	}
+	claims, _, err := interceptServerClaims(ctx, broker, newOptions(opts...))
+	return claims.Consumer, err
 }
 
-func (s *authenticatedClientStream) Context() context.Context {
-	return ContextWithAuthTokenMetadata(s.ClientStream.Context(), s.jwt)
+// interceptServerClaims will check the context metadata for a JWT and return its claims and the raw token,
+// or the claims of the anonymous consumer when the auth mode allows the request without valid credentials.
+func interceptServerClaims(ctx context.Context, broker CopierRenewer, o *options) (lushauth.Claims, string, error) {
+	claims, raw, err := parseServerClaims(ctx, broker, o)
+	if err != nil && o.mode.anonymous(err == ErrMetadataMissing || err == ErrAuthTokenMissing) {
+		claims, raw, err = lushauth.Claims{Consumer: lushauth.AnonymousConsumer()}, "", nil
+	}
+	err = handleInterceptError(err)
+	method, _ := grpc.Method(ctx)
+	o.hooks.AuthEvent(ctx, authenticationEvent(method, claims, err))
+	return claims, raw, err
 }
 
-// InterceptServerJWT will check the context metadata for a JWT
-func InterceptServerJWT(ctx context.Context, broker CopierRenewer) (lushauth.Consumer, error) {
-	var consumer lushauth.Consumer
+// parseServerClaims will check the context metadata for a JWT and validate it.
+func parseServerClaims(ctx context.Context, broker CopierRenewer, o *options) (lushauth.Claims, string, error) {
+	var none lushauth.Claims
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return consumer, ErrMetadataMissing
+		return none, "", ErrMetadataMissing
 	}
-	tokens := md.Get(metaAuthTokenKey)
-	if len(tokens) < 1 {
-		return consumer, ErrAuthTokenMissing
+	raw, ok := tokenFromMetadata(md, o.extractors)
+	if !ok {
+		return none, "", ErrAuthTokenMissing
 	}
-	raw := tokens[0]
-	pk := broker.Copy()
-	parser := auth.NewParser(&pk, lushauth.RSAKeyFunc)
 	var claims lushauth.Claims
-	err := parser.Parse(raw, &claims)
-	if err != nil {
+	if err := parseClaims(raw, broker, o.keyfunc, &claims); err != nil {
 		var e *jwt.ValidationError
 		if errors.As(err, &e) {
-			if e.Inner != nil {
-				switch ierr := e.Inner.(type) {
-				case lushauth.JWTVerificationError:
-					return consumer, status.Error(codes.Unauthenticated, ierr.Error())
-				case lushauth.JWTSigningMethodError:
-					broker.Renew() // Renew the public key if there's an error validating the token signature
-				}
+			if ierr, ok := e.Inner.(lushauth.JWTVerificationError); ok {
+				return none, "", status.Error(codes.Unauthenticated, ierr.Error())
 			}
 		}
-		return consumer, status.Error(codes.InvalidArgument, err.Error())
+		return none, "", status.Error(codes.Unauthenticated, err.Error())
 	}
-	return claims.Consumer, nil
+	if o.revocation != nil {
+		if err := lushauth.CheckRevocation(ctx, o.revocation, claims); err != nil {
+			if _, ok := err.(lushauth.JWTRevokedError); ok {
+				return none, "", status.Error(codes.Unauthenticated, err.Error())
+			}
+			return none, "", revocationUnavailableError{err}
+		}
+	}
+	if err := claims.Extra.Validate(o.extra...); err != nil {
+		return none, "", status.Error(codes.Unauthenticated, err.Error())
+	}
+	return claims, raw, nil
 }
 
+// handleInterceptError logs the error of intercepting a call, except for missing credentials which are expected,
+// and hides the reason why the revocation of a token could not be checked from the caller.
 func handleInterceptError(err error) error {
-	switch err {
-	case nil:
-		return nil
-	case
-		ErrMetadataMissing,
-		ErrAuthTokenMissing:
-		return nil
-	default:
+	if err != nil && err != ErrMetadataMissing && err != ErrAuthTokenMissing {
 		log.Printf("grpc auth middleware error: %v\n", err)
-		return err
 	}
+	if _, ok := err.(revocationUnavailableError); ok {
+		return status.Error(codes.Unavailable, msgRevocationUnavailable)
+	}
+	return err
 }
 
 // UnaryServerInterceptor is a gRPC server-side interceptor that checks that JWT provided is valid for unary procedures
-func UnaryServerInterceptor(broker CopierRenewer) func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+func UnaryServerInterceptor(broker CopierRenewer, opts ...Option) func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	o := newOptions(opts...)
+	broker = coordinate(broker)
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		consumer, err := InterceptServerJWT(ctx, broker)
-		if err := handleInterceptError(err); err != nil {
+		claims, raw, err := interceptServerClaims(ctx, broker, o)
+		if err != nil {
 			return nil, err
 		}
-		resp, err := handler(lushauth.ContextWithConsumer(ctx, consumer), req)
+		resp, err := handler(contextWithAuth(ctx, claims, raw), req)
 		return resp, err
 	}
 }
 
 // StreamServerInterceptor is a gRPC server-side interceptor that checks that JWT provided is valid for streaming procedures
-func StreamServerInterceptor(broker CopierRenewer) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+func StreamServerInterceptor(broker CopierRenewer, opts ...Option) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	o := newOptions(opts...)
+	broker = coordinate(broker)
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		consumer, err := InterceptServerJWT(ss.Context(), broker)
-		if err := handleInterceptError(err); err != nil {
+		claims, raw, err := interceptServerClaims(ss.Context(), broker, o)
+		if err != nil {
 			return err
 		}
-		err = handler(srv, &authenticatedServerStream{ss, consumer})
+		err = handler(srv, &authenticatedServerStream{ss, contextWithAuth(ss.Context(), claims, raw)})
 		return err
 	}
 }
 
 type authenticatedServerStream struct {
 	grpc.ServerStream
-	consumer lushauth.Consumer
+	ctx context.Context
 }
 
 func (s *authenticatedServerStream) Context() context.Context {
-	return lushauth.ContextWithConsumer(s.ServerStream.Context(), s.consumer)
+	return s.ctx
 }
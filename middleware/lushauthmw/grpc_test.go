@@ -1,8 +1,12 @@
 package lushauthmw_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"log"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/LUSHDigital/core-lush/lushauth"
@@ -73,15 +77,16 @@ func TestUnaryServerInterceptor(t *testing.T) {
 			name:    "no token",
 			tokens:  nil,
 			handler: ok,
-			code:    codes.OK,
-			errors:  false,
+			errors:  true,
+			code:    codes.Unauthenticated,
+			message: "metadata missing: auth-token",
 		},
 		{
 			name:    "empty token",
 			tokens:  []string{""},
 			handler: ok,
 			errors:  true,
-			code:    codes.InvalidArgument,
+			code:    codes.Unauthenticated,
 			message: "token contains an invalid number of segments",
 		},
 		{
@@ -89,7 +94,7 @@ func TestUnaryServerInterceptor(t *testing.T) {
 			tokens:  []string{"abcd123!"},
 			handler: ok,
 			errors:  true,
-			code:    codes.InvalidArgument,
+			code:    codes.Unauthenticated,
 			message: "token contains an invalid number of segments",
 		},
 	}
@@ -133,28 +138,28 @@ func TestInterceptServerJWT(t *testing.T) {
 		{
 			name:    "missing token",
 			errors:  true,
-			code:    codes.InvalidArgument,
+			code:    codes.Unauthenticated,
 			message: "metadata missing: auth-token",
 		},
 		{
 			name:    "malformed token",
 			token:   "123",
 			errors:  true,
-			code:    codes.InvalidArgument,
+			code:    codes.Unauthenticated,
 			message: "token contains an invalid number of segments",
 		},
 		{
 			name:    "incorrect signing method",
 			token:   "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0.eyJjb25zdW1lciI6eyJpZCI6OTk5LCJmaXJzdF9uYW1lIjoiVGVzdHkiLCJsYXN0X25hbWUiOiJNY1Rlc3QiLCJsYW5ndWFnZSI6IiIsImdyYW50cyI6WyJ0ZXN0aW5nLnJlYWQiLCJ0ZXN0aW5nLmNyZWF0ZSJdfSwiZXhwIjoxNTE4NjAzNzIwLCJqdGkiOiIyNTAwYjk3MS0wNTcxLTQ4Y2UtYmUzOS1jNWJhNGQwZmU0MGIiLCJpc3MiOiJ0ZXN0aW5nIn0.",
 			errors:  true,
-			code:    codes.InvalidArgument,
+			code:    codes.Unauthenticated,
 			message: "unexpected signing method (needs to be RSA): none",
 		},
 		{
 			name:    "invalid claims",
 			token:   invalidToken,
 			errors:  true,
-			code:    codes.InvalidArgument,
+			code:    codes.Unauthenticated,
 			message: "crypto/rsa: verification error",
 		},
 		{
@@ -223,3 +228,142 @@ func TestContextWithAuthTokenMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestInterceptServerJWT_Revocation(t *testing.T) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	revoked := lushauth.NewRevocationList()
+	revoked.RevokeClaims(validClaims)
+	md := metadata.MD{}
+	md.Set("auth-token", validToken)
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	t.Run("token has not been revoked", func(t *testing.T) {
+		_, err := lushauthmw.InterceptServerJWT(ctx, broker, lushauthmw.WithRevocationChecker(lushauth.NewRevocationList()))
+		test.Equals(t, nil, err)
+	})
+	t.Run("token has been revoked", func(t *testing.T) {
+		_, err := lushauthmw.InterceptServerJWT(ctx, broker, lushauthmw.WithRevocationChecker(revoked))
+		test.Equals(t, codes.Unauthenticated, status.Code(err))
+	})
+	t.Run("revocation store is unavailable", func(t *testing.T) {
+		unavailable := lushauth.RevocationCheckerFunc(func(ctx context.Context, c lushauth.Claims) (bool, error) {
+			return false, errors.New("dial tcp 10.0.0.1:6379: connection refused")
+		})
+		_, err := lushauthmw.InterceptServerJWT(ctx, broker, lushauthmw.WithRevocationChecker(unavailable))
+		test.Equals(t, status.Error(codes.Unavailable, "token revocation could not be checked"), err)
+	})
+}
+
+func TestServerInterceptors_RevocationUnavailableLogsOnce(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	broker := keybrokermock.MockRSAPublicKey(public)
+	unavailable := lushauthmw.WithRevocationChecker(lushauth.RevocationCheckerFunc(func(ctx context.Context, c lushauth.Claims) (bool, error) {
+		return false, errors.New("redis down")
+	}))
+	md := metadata.MD{}
+	md.Set("auth-token", validToken)
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	info := &grpc.UnaryServerInfo{FullMethod: "/users.Users/Get"}
+	interceptors := map[string]grpc.UnaryServerInterceptor{
+		"unary":  lushauthmw.UnaryServerInterceptor(broker, unavailable),
+		"policy": lushauthmw.UnaryServerPolicyInterceptor(broker, nil, unavailable),
+	}
+	for name, mw := range interceptors {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			_, err := mw(ctx, nil, info, ok)
+			test.Equals(t, status.Error(codes.Unavailable, "token revocation could not be checked"), err)
+			test.Equals(t, 1, strings.Count(buf.String(), "\n"))
+			test.Equals(t, true, strings.HasSuffix(buf.String(), "grpc auth middleware error: redis down\n"))
+		})
+	}
+}
+
+func TestUnaryServerInterceptor_Service(t *testing.T) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	service := lushauth.Service{ClientID: "stock-sync", Scopes: []string{"stock.update"}}
+	token, err := lushauth.NewServiceIssuer(issuer, "Test", service).Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := metadata.MD{}
+	md.Set("auth-token", token)
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	mw := lushauthmw.UnaryServerInterceptor(broker)
+	_, err = mw(ctx, nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		s, ok := lushauth.ServiceFromContext(ctx)
+		test.Equals(t, true, ok)
+		test.Equals(t, service, s)
+		return nil, nil
+	})
+	test.Equals(t, nil, err)
+}
+
+func TestInterceptServerJWT_ExtraClaims(t *testing.T) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	md := metadata.MD{}
+	md.Set("auth-token", validToken)
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	_, err := lushauthmw.InterceptServerJWT(ctx, broker, lushauthmw.WithExtraClaims(TierClaim("")))
+	test.Equals(t, status.Error(codes.Unauthenticated, "missing extra claim: tier"), err)
+}
+
+func TestUnaryServerInterceptor_AuthMode(t *testing.T) {
+	cases := []struct {
+		name              string
+		mode              lushauthmw.AuthMode
+		token             string
+		expectedCode      codes.Code
+		expectedAnonymous bool
+	}{
+		{
+			name:         "required without token",
+			mode:         lushauthmw.AuthRequired,
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:              "optional without token",
+			mode:              lushauthmw.AuthOptional,
+			expectedCode:      codes.OK,
+			expectedAnonymous: true,
+		},
+		{
+			name:         "optional with expired token",
+			mode:         lushauthmw.AuthOptional,
+			token:        expiredToken,
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "anonymous with token",
+			mode:         lushauthmw.AuthAnonymous,
+			token:        validToken,
+			expectedCode: codes.OK,
+		},
+		{
+			name:              "anonymous with expired token",
+			mode:              lushauthmw.AuthAnonymous,
+			token:             expiredToken,
+			expectedCode:      codes.OK,
+			expectedAnonymous: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			md := metadata.MD{}
+			if c.token != "" {
+				md.Set("auth-token", c.token)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			var anonymous bool
+			mw := lushauthmw.UnaryServerInterceptor(broker, lushauthmw.WithAuthMode(c.mode))
+			_, err := mw(ctx, nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+				anonymous = lushauth.ConsumerFromContext(ctx).IsAnonymous()
+				return nil, nil
+			})
+			test.Equals(t, c.expectedCode, status.Code(err))
+			test.Equals(t, c.expectedAnonymous, anonymous)
+		})
+	}
+}
@@ -1,20 +1,80 @@
 package lushauthmw
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/LUSHDigital/core-lush/lushauth"
-	"github.com/LUSHDigital/core/auth"
 	"github.com/LUSHDigital/core/rest"
 )
 
 const (
 	authHeader               = "Authorization"
 	authHeaderPrefix         = "Bearer "
+	challengeHeader          = "WWW-Authenticate"
+	challengeScheme          = "Bearer"
+	msgMissingToken          = "missing bearer token"
 	msgMissingRequiredGrants = "missing required grants"
+	msgMissingRequiredRoles  = "missing required roles"
+	msgRevocationUnavailable = "token revocation could not be checked"
 )
 
+var (
+	errTokenMissing          = errors.New(msgMissingToken)
+	errMissingRequiredGrants = errors.New(msgMissingRequiredGrants)
+	errMissingRequiredRoles  = errors.New(msgMissingRequiredRoles)
+)
+
+// Error codes for bearer token challenges, as referenced at
+// https://tools.ietf.org/html/rfc6750#section-3.1
+const (
+	bearerErrorInvalidToken      = "invalid_token"
+	bearerErrorInsufficientScope = "insufficient_scope"
+)
+
+// challenge sets a bearer token challenge on the response with an optional error code and description.
+func challenge(w http.ResponseWriter, code, description string) {
+	if code == "" {
+		w.Header().Set(challengeHeader, challengeScheme)
+		return
+	}
+	description = strings.Map(func(r rune) rune {
+		// The description can only contain visible ASCII characters except for '"' and '\'.
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, description)
+	w.Header().Set(challengeHeader, fmt.Sprintf(`%s error="%s", error_description="%s"`, challengeScheme, code, description))
+}
+
+// unauthorized responds with 401 Unauthorized for missing or bad credentials.
+func unauthorized(w http.ResponseWriter, code, message string) {
+	challenge(w, code, message)
+	res := &rest.Response{Code: http.StatusUnauthorized, Message: message}
+	res.WriteTo(w)
+}
+
+// forbidden responds with 403 Forbidden for consumers that are not permitted access.
+func forbidden(w http.ResponseWriter, message string) {
+	challenge(w, bearerErrorInsufficientScope, message)
+	res := &rest.Response{Code: http.StatusForbidden, Message: message}
+	res.WriteTo(w)
+}
+
+// deny responds to a consumer that is not permitted access.
+// The anonymous consumer is asked to authenticate with 401 Unauthorized, since it has not provided any credentials.
+func deny(w http.ResponseWriter, consumer lushauth.Consumer, message string) {
+	if consumer.IsAnonymous() {
+		unauthorized(w, "", msgMissingToken)
+		return
+	}
+	forbidden(w, message)
+}
+
 // MiddlewareFunc is a function which receives an http.Handler and returns another http.Handler.
 // Typically, the returned handler is a closure which does something with the http.ResponseWriter and http.Request passed
 // to it, and then calls the handler passed as parameter to the MiddlewareFunc.
@@ -26,54 +86,128 @@ func (mw MiddlewareFunc) Middleware(handler http.Handler) http.Handler {
 }
 
 // JWTMiddleware returns the middleware function for a jwt.
-func JWTMiddleware(cr CopierRenewer) MiddlewareFunc {
+func JWTMiddleware(cr CopierRenewer, opts ...Option) MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
-		return JWTHandler(cr, next.ServeHTTP)
+		return JWTHandler(cr, next.ServeHTTP, opts...)
 	}
 }
 
 // JWTHandler takes a JWT from the request headers, attempts validation and returns a http handler.
-func JWTHandler(cr CopierRenewer, next http.HandlerFunc) http.HandlerFunc {
+func JWTHandler(cr CopierRenewer, next http.HandlerFunc, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts...)
+	cr = coordinate(cr)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		raw := strings.TrimPrefix(r.Header.Get(authHeader), authHeaderPrefix)
-		pk := cr.Copy()
-		parser := auth.NewParser(&pk, lushauth.RSAKeyFunc)
-		var claims lushauth.Claims
-		err := parser.Parse(raw, &claims)
+		claims, raw, err := authenticateRequest(r, cr, o)
+		if err != nil && o.mode.anonymous(err == errTokenMissing) {
+			claims, raw, err = lushauth.Claims{Consumer: lushauth.AnonymousConsumer()}, "", nil
+		}
+		o.hooks.AuthEvent(r.Context(), authenticationEvent(r.URL.Path, claims, err))
 		if err != nil {
-			switch err.(type) {
-			case lushauth.JWTSigningMethodError:
-				cr.Renew() // Renew the public key if there's an error validating the token signature
-			}
-			res := &rest.Response{Code: http.StatusUnauthorized, Message: err.Error()}
-			res.WriteTo(w)
+			refuse(w, err)
 			return
 		}
-		ctx := lushauth.ContextWithConsumer(r.Context(), claims.Consumer)
+		ctx := contextWithAuth(r.Context(), claims, raw)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// authenticateRequest takes a JWT from the request, validates it and returns its claims and the raw token.
+func authenticateRequest(r *http.Request, cr CopierRenewer, o *options) (lushauth.Claims, string, error) {
+	var none lushauth.Claims
+	raw, ok := tokenFromRequest(r, o.extractors)
+	if !ok {
+		return none, "", errTokenMissing
+	}
+	var claims lushauth.Claims
+	if err := parseClaims(raw, cr, o.keyfunc, &claims); err != nil {
+		return none, "", err
+	}
+	if o.revocation != nil {
+		if err := lushauth.CheckRevocation(r.Context(), o.revocation, claims); err != nil {
+			if _, ok := err.(lushauth.JWTRevokedError); ok {
+				return none, "", err
+			}
+			return none, "", revocationUnavailableError{err}
+		}
+	}
+	if err := claims.Extra.Validate(o.extra...); err != nil {
+		return none, "", err
+	}
+	return claims, raw, nil
+}
+
+// revocationUnavailableError happens when the revocation of a token could not be checked.
+type revocationUnavailableError struct {
+	err error
+}
+
+func (e revocationUnavailableError) Error() string {
+	return e.err.Error()
+}
+
+// refuse responds to a request that failed to authenticate.
+func refuse(w http.ResponseWriter, err error) {
+	if _, ok := err.(revocationUnavailableError); ok {
+		log.Printf("http auth middleware error: %v\n", err)
+		res := &rest.Response{Code: http.StatusServiceUnavailable, Message: msgRevocationUnavailable}
+		res.WriteTo(w)
+		return
+	}
+	if err == errTokenMissing {
+		unauthorized(w, "", err.Error())
+		return
+	}
+	unauthorized(w, bearerErrorInvalidToken, err.Error())
+}
+
 // HandlerGrants is an HTTP handler to check that the consumer in the request context has the required grants.
-func HandlerGrants(grants []string, next http.HandlerFunc) http.HandlerFunc {
+func HandlerGrants(grants []string, next http.HandlerFunc, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts...)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		consumer := lushauth.ConsumerFromContext(r.Context())
 		if !consumer.HasAnyGrant(grants...) {
-			res := &rest.Response{Code: http.StatusUnauthorized, Message: msgMissingRequiredGrants}
-			res.WriteTo(w)
+			o.hooks.AuthEvent(r.Context(), authorizationEvent(r.Context(), r.URL.Path, errMissingRequiredGrants))
+			deny(w, consumer, msgMissingRequiredGrants)
 			return
 		}
+		o.hooks.AuthEvent(r.Context(), authorizationEvent(r.Context(), r.URL.Path, nil))
 		next.ServeHTTP(w, r)
 	})
 }
 
 // HandlerRoles is an HTTP handler to check that the consumer in the request context has the required roles.
-func HandlerRoles(roles []string, next http.HandlerFunc) http.HandlerFunc {
+func HandlerRoles(roles []string, next http.HandlerFunc, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts...)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		consumer := lushauth.ConsumerFromContext(r.Context())
 		if !consumer.HasAnyRole(roles...) {
-			res := &rest.Response{Code: http.StatusUnauthorized, Message: msgMissingRequiredGrants}
-			res.WriteTo(w)
+			o.hooks.AuthEvent(r.Context(), authorizationEvent(r.Context(), r.URL.Path, errMissingRequiredRoles))
+			deny(w, consumer, msgMissingRequiredRoles)
+			return
+		}
+		o.hooks.AuthEvent(r.Context(), authorizationEvent(r.Context(), r.URL.Path, nil))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// PolicyMiddleware returns the middleware function for a policy.
+// It needs to be used after JWTMiddleware so that the consumer is available in the request context.
+func PolicyMiddleware(p lushauth.Permitter, opts ...Option) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return HandlerPolicy(p, next.ServeHTTP, opts...)
+	}
+}
+
+// HandlerPolicy is an HTTP handler to check that the consumer in the request context is permitted by the policy.
+// A ContextPolicy is evaluated with the context of the request.
+func HandlerPolicy(p lushauth.Permitter, next http.HandlerFunc, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consumer := lushauth.ConsumerFromContext(r.Context())
+		err := permitWithContext(r.Context(), p, consumer)
+		o.hooks.AuthEvent(r.Context(), authorizationEvent(r.Context(), r.URL.Path, err))
+		if err != nil {
+			deny(w, consumer, err.Error())
 			return
 		}
 		next.ServeHTTP(w, r)
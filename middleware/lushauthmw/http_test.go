@@ -1,6 +1,9 @@
 package lushauthmw_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -79,3 +82,420 @@ func TestJWTHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestJWTHandler_Revocation(t *testing.T) {
+	revoked := lushauth.NewRevocationList()
+	revoked.RevokeClaims(validClaims)
+	cases := []struct {
+		name               string
+		checker            lushauth.RevocationChecker
+		expectedStatusCode int
+	}{
+		{
+			name:               "token has not been revoked",
+			checker:            lushauth.NewRevocationList(),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "token has been revoked",
+			checker:            revoked,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "revocation store is unavailable",
+			checker: lushauth.RevocationCheckerFunc(func(ctx context.Context, c lushauth.Claims) (bool, error) {
+				return false, errors.New("store unavailable")
+			}),
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			req, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Add("Authorization", "Bearer "+validToken)
+			recorder := httptest.NewRecorder()
+			handler := lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}, lushauthmw.WithRevocationChecker(c.checker))
+			handler.ServeHTTP(recorder, req)
+			test.Equals(t, c.expectedStatusCode, recorder.Code)
+			if c.expectedStatusCode == http.StatusServiceUnavailable {
+				var res rest.Response
+				if err := json.Unmarshal(recorder.Body.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+				test.Equals(t, "token revocation could not be checked", res.Message)
+			}
+		})
+	}
+}
+
+type TierClaim string
+
+func (t TierClaim) ClaimName() string {
+	return "tier"
+}
+
+func TestJWTHandler_ExtraClaims(t *testing.T) {
+	claims := validClaims
+	claims.Extra.Set(TierClaim("gold"))
+	cases := []struct {
+		name               string
+		token              string
+		expectedStatusCode int
+		expectedTier       TierClaim
+	}{
+		{
+			name:               "token with extra claim",
+			token:              mustIssue(issuer.Issue(&claims)),
+			expectedStatusCode: http.StatusOK,
+			expectedTier:       "gold",
+		},
+		{
+			name:               "token without extra claim",
+			token:              validToken,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			req, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Add("Authorization", "Bearer "+c.token)
+			recorder := httptest.NewRecorder()
+			var tier TierClaim
+			handler := lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {
+				lushauth.ExtraClaimsFromContext(r.Context()).Get(&tier)
+				w.WriteHeader(http.StatusOK)
+			}, lushauthmw.WithExtraClaims(TierClaim("")))
+			handler.ServeHTTP(recorder, req)
+			test.Equals(t, c.expectedStatusCode, recorder.Code)
+			test.Equals(t, c.expectedTier, tier)
+		})
+	}
+}
+
+func ExamplePolicyMiddleware() {
+	router.Use(
+		lushauthmw.JWTMiddleware(broker),
+		lushauthmw.PolicyMiddleware(lushauth.AnyPolicy{
+			lushauth.MarketPolicy{ID: "gb", Roles: []string{"digital_manager"}},
+			lushauth.RolePolicy{"admin"},
+		}),
+	)
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	cases := []struct {
+		name               string
+		policy             lushauth.Permitter
+		token              string
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name:               "consumer is permitted",
+			policy:             lushauth.UserPolicy{validClaims.Consumer.UUID},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "consumer is not permitted",
+			policy:             lushauth.RolePolicy{"admin"},
+			expectedStatusCode: http.StatusForbidden,
+			expectedMessage:    lushauth.RolePolicy{"admin"}.Error(),
+		},
+		{
+			name:               "consumer is permitted by context policy",
+			policy:             lushauthmw.ContextPolicy{Policy: lushauth.NoImpersonationPolicy{}},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "impersonation is denied by context policy",
+			policy:             lushauthmw.ContextPolicy{Policy: lushauth.NoImpersonationPolicy{}},
+			token:              impersonatedAdminToken(t),
+			expectedStatusCode: http.StatusForbidden,
+			expectedMessage:    lushauth.NoImpersonationPolicy{}.Error(),
+		},
+		{
+			name:               "impersonation is denied by nested context policy",
+			policy:             lushauth.AllPolicy{lushauth.RolePolicy{"admin"}, lushauthmw.ContextPolicy{Policy: lushauth.NoImpersonationPolicy{}}},
+			token:              impersonatedAdminToken(t),
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			req, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token := c.token
+			if token == "" {
+				token = validToken
+			}
+			req.Header.Add("Authorization", "Bearer "+token)
+			recorder := httptest.NewRecorder()
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler = lushauthmw.PolicyMiddleware(c.policy)(handler)
+			handler = lushauthmw.JWTMiddleware(broker)(handler)
+			handler.ServeHTTP(recorder, req)
+			test.Equals(t, c.expectedStatusCode, recorder.Code)
+			if c.expectedMessage != "" {
+				var res rest.Response
+				if err := json.Unmarshal(recorder.Body.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+				test.Equals(t, c.expectedMessage, res.Message)
+			}
+		})
+	}
+}
+
+func TestJWTHandler_Challenge(t *testing.T) {
+	cases := []struct {
+		name              string
+		header            string
+		expectedChallenge string
+	}{
+		{
+			name:              "token is missing",
+			expectedChallenge: "Bearer",
+		},
+		{
+			name:              "token is not a bearer token",
+			header:            "Basic dXNlcjpwYXNz",
+			expectedChallenge: "Bearer",
+		},
+		{
+			name:              "token is malformed",
+			header:            "Bearer i am invalid",
+			expectedChallenge: `Bearer error="invalid_token", error_description="token contains an invalid number of segments"`,
+		},
+		{
+			name:              "token has expired",
+			header:            "Bearer " + expiredToken,
+			expectedChallenge: `Bearer error="invalid_token", error_description="could not verify token: has expired"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			req, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.header != "" {
+				req.Header.Add("Authorization", c.header)
+			}
+			recorder := httptest.NewRecorder()
+			handler := lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler.ServeHTTP(recorder, req)
+			test.Equals(t, http.StatusUnauthorized, recorder.Code)
+			test.Equals(t, c.expectedChallenge, recorder.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestHandlerGrantsAndRoles(t *testing.T) {
+	consumer := lushauth.Consumer{Grants: []string{"users.read"}, Roles: []string{"staff"}}
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	cases := []struct {
+		name               string
+		handler            http.HandlerFunc
+		expectedStatusCode int
+		expectedChallenge  string
+	}{
+		{
+			name:               "consumer has the required grants",
+			handler:            lushauthmw.HandlerGrants([]string{"users.read"}, next),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "consumer is missing the required grants",
+			handler:            lushauthmw.HandlerGrants([]string{"users.delete"}, next),
+			expectedStatusCode: http.StatusForbidden,
+			expectedChallenge:  `Bearer error="insufficient_scope", error_description="missing required grants"`,
+		},
+		{
+			name:               "consumer has the required roles",
+			handler:            lushauthmw.HandlerRoles([]string{"staff"}, next),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "consumer is missing the required roles",
+			handler:            lushauthmw.HandlerRoles([]string{"admin"}, next),
+			expectedStatusCode: http.StatusForbidden,
+			expectedChallenge:  `Bearer error="insufficient_scope", error_description="missing required roles"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(lushauth.ContextWithConsumer(req.Context(), consumer))
+			recorder := httptest.NewRecorder()
+			c.handler.ServeHTTP(recorder, req)
+			test.Equals(t, c.expectedStatusCode, recorder.Code)
+			test.Equals(t, c.expectedChallenge, recorder.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestJWTHandler_AuthMode(t *testing.T) {
+	cases := []struct {
+		name               string
+		mode               lushauthmw.AuthMode
+		token              string
+		expectedStatusCode int
+		expectedAnonymous  bool
+	}{
+		{
+			name:               "required with token",
+			mode:               lushauthmw.AuthRequired,
+			token:              validToken,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "required without token",
+			mode:               lushauthmw.AuthRequired,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "optional with token",
+			mode:               lushauthmw.AuthOptional,
+			token:              validToken,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "optional without token",
+			mode:               lushauthmw.AuthOptional,
+			expectedStatusCode: http.StatusOK,
+			expectedAnonymous:  true,
+		},
+		{
+			name:               "optional with expired token",
+			mode:               lushauthmw.AuthOptional,
+			token:              expiredToken,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "anonymous with token",
+			mode:               lushauthmw.AuthAnonymous,
+			token:              validToken,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "anonymous without token",
+			mode:               lushauthmw.AuthAnonymous,
+			expectedStatusCode: http.StatusOK,
+			expectedAnonymous:  true,
+		},
+		{
+			name:               "anonymous with expired token",
+			mode:               lushauthmw.AuthAnonymous,
+			token:              expiredToken,
+			expectedStatusCode: http.StatusOK,
+			expectedAnonymous:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			req, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.token != "" {
+				req.Header.Add("Authorization", "Bearer "+c.token)
+			}
+			recorder := httptest.NewRecorder()
+			var anonymous bool
+			handler := lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {
+				anonymous = lushauth.ConsumerFromContext(r.Context()).IsAnonymous()
+				w.WriteHeader(http.StatusOK)
+			}, lushauthmw.WithAuthMode(c.mode))
+			handler.ServeHTTP(recorder, req)
+			test.Equals(t, c.expectedStatusCode, recorder.Code)
+			test.Equals(t, c.expectedAnonymous, anonymous)
+		})
+	}
+}
+
+func TestJWTHandler_AuthModeWithPolicy(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {}
+	cases := []struct {
+		name               string
+		mode               lushauthmw.AuthMode
+		token              string
+		handler            http.HandlerFunc
+		expectedStatusCode int
+		expectedChallenge  string
+	}{
+		{
+			name:               "optional without token denied by policy",
+			mode:               lushauthmw.AuthOptional,
+			handler:            lushauthmw.HandlerPolicy(lushauth.RolePolicy{"admin"}, next),
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedChallenge:  `Bearer`,
+		},
+		{
+			name:               "optional without token denied by grants",
+			mode:               lushauthmw.AuthOptional,
+			handler:            lushauthmw.HandlerGrants([]string{"users.delete"}, next),
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedChallenge:  `Bearer`,
+		},
+		{
+			name:               "anonymous with expired token denied by roles",
+			mode:               lushauthmw.AuthAnonymous,
+			token:              expiredToken,
+			handler:            lushauthmw.HandlerRoles([]string{"admin"}, next),
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedChallenge:  `Bearer`,
+		},
+		{
+			name:               "optional with token denied by policy",
+			mode:               lushauthmw.AuthOptional,
+			token:              validToken,
+			handler:            lushauthmw.HandlerPolicy(lushauth.RolePolicy{"admin"}, next),
+			expectedStatusCode: http.StatusForbidden,
+			expectedChallenge:  `Bearer error="insufficient_scope", error_description="need to have any of the admin roles"`,
+		},
+		{
+			name:               "optional with token permitted by policy",
+			mode:               lushauthmw.AuthOptional,
+			token:              validToken,
+			handler:            lushauthmw.HandlerPolicy(lushauth.UserPolicy{validClaims.Consumer.UUID}, next),
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			recorder := httptest.NewRecorder()
+			lushauthmw.JWTHandler(broker, c.handler, lushauthmw.WithAuthMode(c.mode)).ServeHTTP(recorder, req)
+			test.Equals(t, c.expectedStatusCode, recorder.Code)
+			test.Equals(t, c.expectedChallenge, recorder.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package lushauthprom provides Prometheus metrics for the decisions made by the lushauthmw auth middlewares.
+package lushauthprom
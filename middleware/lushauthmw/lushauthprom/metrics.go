@@ -0,0 +1,66 @@
+package lushauthprom
+
+import (
+	"context"
+	"strings"
+
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// otherRoute is the route label of the events that do not match any of the routes of a metrics hook.
+const otherRoute = "other"
+
+// MetricsHook counts the decisions made by the auth middlewares by route and outcome.
+// It is a prometheus.Collector which needs to be registered to be exported.
+type MetricsHook struct {
+	decisions *prometheus.CounterVec
+	routes    []string
+}
+
+// NewMetricsHook creates a metrics hook for a namespace, labelling decisions by the routes they match.
+// Routes are HTTP paths or full gRPC method names, where a trailing * matches any route with the same prefix.
+// An exact match takes precedence over the longest wildcard match, and the route is labelled as "other" when nothing matches.
+// Only the routes are used as labels so that requests to arbitrary paths can not create an unbounded number of series.
+func NewMetricsHook(namespace string, routes ...string) *MetricsHook {
+	return &MetricsHook{
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "decisions_total",
+			Help:      "Total number of authentication and authorization decisions by route and outcome.",
+		}, []string{"route", "outcome"}),
+		routes: routes,
+	}
+}
+
+// route returns the route label for a route.
+func (h *MetricsHook) route(route string) string {
+	label := otherRoute
+	longest := -1
+	for _, pattern := range h.routes {
+		if pattern == route {
+			return pattern
+		}
+		prefix := strings.TrimSuffix(pattern, "*")
+		if prefix != pattern && len(pattern) > longest && strings.HasPrefix(route, prefix) {
+			label, longest = pattern, len(pattern)
+		}
+	}
+	return label
+}
+
+// AuthEvent counts an event.
+func (h *MetricsHook) AuthEvent(ctx context.Context, e lushauthmw.Event) {
+	h.decisions.WithLabelValues(h.route(e.Route), string(e.Outcome)).Inc()
+}
+
+// Describe sends the descriptors of the metrics to the channel.
+func (h *MetricsHook) Describe(ch chan<- *prometheus.Desc) {
+	h.decisions.Describe(ch)
+}
+
+// Collect sends the metrics to the channel.
+func (h *MetricsHook) Collect(ch chan<- prometheus.Metric) {
+	h.decisions.Collect(ch)
+}
@@ -0,0 +1,62 @@
+package lushauthprom_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw/lushauthprom"
+	"github.com/LUSHDigital/core/test"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var broker lushauthmw.CopierRenewer
+
+func ExampleNewMetricsHook() {
+	metrics := lushauthprom.NewMetricsHook("orders", "/orders/*", "/orders.Orders/*")
+	prometheus.MustRegister(metrics)
+	hooks := lushauthmw.WithEventHooks(metrics, lushauthmw.NewAuditLog(os.Stdout))
+	lushauthmw.NewUnaryServerInterceptor(broker, hooks)
+}
+
+func TestMetricsHook(t *testing.T) {
+	metrics := lushauthprom.NewMetricsHook("test", "/orders", "/orders/*", "/orders.Orders/*")
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(metrics)
+
+	ctx := context.Background()
+	for _, e := range []lushauthmw.Event{
+		{Route: "/orders", Outcome: lushauthmw.OutcomeAuthenticated},
+		{Route: "/orders/1", Outcome: lushauthmw.OutcomeAuthenticated},
+		{Route: "/orders/2", Outcome: lushauthmw.OutcomeAuthenticated},
+		{Route: "/orders/2", Outcome: lushauthmw.OutcomeDenied},
+		{Route: "/orders.Orders/Get", Outcome: lushauthmw.OutcomePermitted},
+		{Route: "/random/3c8e1a", Outcome: lushauthmw.OutcomeUnauthenticated},
+		{Route: "/random/9f2b77", Outcome: lushauthmw.OutcomeUnauthenticated},
+	} {
+		metrics.AuthEvent(ctx, e)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Equals(t, 1, len(families))
+	test.Equals(t, "test_auth_decisions_total", families[0].GetName())
+	counts := make(map[string]float64)
+	for _, m := range families[0].GetMetric() {
+		labels := make(map[string]string)
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		counts[labels["route"]+" "+labels["outcome"]] = m.GetCounter().GetValue()
+	}
+	test.Equals(t, map[string]float64{
+		"/orders authenticated":      1,
+		"/orders/* authenticated":    2,
+		"/orders/* denied":           1,
+		"/orders.Orders/* permitted": 1,
+		"other unauthenticated":      2,
+	}, counts)
+}
@@ -0,0 +1,142 @@
+package lushauthmw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/rest"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// AnyNeed exempts a route from every need.
+	AnyNeed = "*"
+
+	needsDataType       = "needs"
+	needsViolationType  = "NEED"
+	msgOutstandingNeeds = "outstanding needs"
+)
+
+// NeedsExemptions maps routes to the needs a consumer may have outstanding while using them.
+// Routes are HTTP paths or full gRPC method names, where a trailing * matches any route with the same prefix.
+// e.g. "/password" exempts "password_reset" and "/users.Users/*" exempts AnyNeed
+type NeedsExemptions map[string][]string
+
+// outstanding returns the needs of a consumer that are not exempted for a route.
+func (e NeedsExemptions) outstanding(route string, needs []string) []string {
+	var exempted []string
+	for pattern, exempt := range e {
+		if matchRoute(pattern, route) {
+			exempted = append(exempted, exempt...)
+		}
+	}
+	if hasNeed(exempted, AnyNeed) {
+		return nil
+	}
+	var outstanding []string
+	for _, need := range needs {
+		if !hasNeed(exempted, need) {
+			outstanding = append(outstanding, need)
+		}
+	}
+	return outstanding
+}
+
+func hasNeed(needs []string, need string) bool {
+	for _, n := range needs {
+		if n == need {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsResponse is the content of the response when a consumer has outstanding needs.
+type NeedsResponse struct {
+	Needs []string `json:"needs"`
+}
+
+// NeedsMiddleware returns the middleware function blocking consumers with outstanding needs.
+func NeedsMiddleware(exemptions NeedsExemptions, opts ...Option) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return HandlerNeeds(exemptions, next.ServeHTTP, opts...)
+	}
+}
+
+// HandlerNeeds is an HTTP handler to check that the consumer in the request context has no outstanding needs.
+// The response lists the outstanding needs so that a front-end can ask the consumer to satisfy them.
+func HandlerNeeds(exemptions NeedsExemptions, next http.HandlerFunc, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consumer := lushauth.ConsumerFromContext(r.Context())
+		if needs := exemptions.outstanding(r.URL.Path, consumer.Needs); len(needs) > 0 {
+			message := fmt.Sprintf("%s: %s", msgOutstandingNeeds, strings.Join(needs, ", "))
+			o.hooks.AuthEvent(r.Context(), authorizationEvent(r.Context(), r.URL.Path, errors.New(message)))
+			res := &rest.Response{
+				Code:    http.StatusForbidden,
+				Message: message,
+				Data:    &rest.Data{Type: needsDataType, Content: NeedsResponse{Needs: needs}},
+			}
+			res.WriteTo(w)
+			return
+		}
+		o.hooks.AuthEvent(r.Context(), authorizationEvent(r.Context(), r.URL.Path, nil))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// needsError creates a failed precondition status with a violation for every outstanding need.
+func needsError(needs []string) error {
+	st := status.New(codes.FailedPrecondition, fmt.Sprintf("%s: %s", msgOutstandingNeeds, strings.Join(needs, ", ")))
+	failure := &errdetails.PreconditionFailure{}
+	for _, need := range needs {
+		failure.Violations = append(failure.Violations, &errdetails.PreconditionFailure_Violation{
+			Type:        needsViolationType,
+			Subject:     need,
+			Description: fmt.Sprintf("consumer has the outstanding need %q", need),
+		})
+	}
+	if detailed, err := st.WithDetails(failure); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// UnaryServerNeedsInterceptor is a gRPC server-side interceptor that blocks consumers with outstanding needs for unary procedures.
+// It needs to be chained after the UnaryServerInterceptor.
+func UnaryServerNeedsInterceptor(exemptions NeedsExemptions, opts ...Option) func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	o := newOptions(opts...)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		consumer := lushauth.ConsumerFromContext(ctx)
+		if needs := exemptions.outstanding(info.FullMethod, consumer.Needs); len(needs) > 0 {
+			err := needsError(needs)
+			o.hooks.AuthEvent(ctx, authorizationEvent(ctx, info.FullMethod, err))
+			return nil, err
+		}
+		o.hooks.AuthEvent(ctx, authorizationEvent(ctx, info.FullMethod, nil))
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerNeedsInterceptor is a gRPC server-side interceptor that blocks consumers with outstanding needs for streaming procedures.
+// It needs to be chained after the StreamServerInterceptor.
+func StreamServerNeedsInterceptor(exemptions NeedsExemptions, opts ...Option) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	o := newOptions(opts...)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		consumer := lushauth.ConsumerFromContext(ss.Context())
+		if needs := exemptions.outstanding(info.FullMethod, consumer.Needs); len(needs) > 0 {
+			err := needsError(needs)
+			o.hooks.AuthEvent(ss.Context(), authorizationEvent(ss.Context(), info.FullMethod, err))
+			return err
+		}
+		o.hooks.AuthEvent(ss.Context(), authorizationEvent(ss.Context(), info.FullMethod, nil))
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,207 @@
+package lushauthmw_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/LUSHDigital/core/rest"
+	"github.com/LUSHDigital/core/test"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var exemptions = lushauthmw.NeedsExemptions{
+	"/password":          {"password_reset"},
+	"/terms":             {"accept_terms"},
+	"/logout":            {lushauthmw.AnyNeed},
+	"/users.Users/*":     {"confirm_email"},
+	"/users.Users/Reset": {"password_reset"},
+}
+
+func ExampleNeedsMiddleware() {
+	middleware := lushauthmw.NeedsMiddleware(lushauthmw.NeedsExemptions{
+		"/password": {"password_reset"},
+		"/logout":   {lushauthmw.AnyNeed},
+	})
+	router.Use(middleware)
+}
+
+func TestHandlerNeeds(t *testing.T) {
+	cases := []struct {
+		name               string
+		path               string
+		needs              []string
+		expectedStatusCode int
+		expectedNeeds      []string
+	}{
+		{
+			name:               "consumer without needs",
+			path:               "/orders",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "consumer with outstanding needs",
+			path:               "/orders",
+			needs:              []string{"password_reset", "accept_terms"},
+			expectedStatusCode: http.StatusForbidden,
+			expectedNeeds:      []string{"password_reset", "accept_terms"},
+		},
+		{
+			name:               "consumer with need exempted for the route",
+			path:               "/password",
+			needs:              []string{"password_reset"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "consumer with need exempted and need outstanding for the route",
+			path:               "/password",
+			needs:              []string{"password_reset", "accept_terms"},
+			expectedStatusCode: http.StatusForbidden,
+			expectedNeeds:      []string{"accept_terms"},
+		},
+		{
+			name:               "consumer with needs on route exempted from any need",
+			path:               "/logout",
+			needs:              []string{"password_reset", "accept_terms"},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", c.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(lushauth.ContextWithConsumer(req.Context(), lushauth.Consumer{Needs: c.needs}))
+			recorder := httptest.NewRecorder()
+			handler := lushauthmw.HandlerNeeds(exemptions, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler.ServeHTTP(recorder, req)
+			test.Equals(t, c.expectedStatusCode, recorder.Code)
+			if c.expectedNeeds != nil {
+				var res lushauthmw.NeedsResponse
+				if err := rest.UnmarshalJSONResponse(recorder.Body.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+				test.Equals(t, c.expectedNeeds, res.Needs)
+			}
+		})
+	}
+}
+
+func TestUnaryServerNeedsInterceptor(t *testing.T) {
+	cases := []struct {
+		name          string
+		method        string
+		needs         []string
+		expectedCode  codes.Code
+		expectedNeeds []string
+	}{
+		{
+			name:         "consumer without needs",
+			method:       "/orders.Orders/Get",
+			expectedCode: codes.OK,
+		},
+		{
+			name:          "consumer with outstanding needs",
+			method:        "/orders.Orders/Get",
+			needs:         []string{"confirm_email"},
+			expectedCode:  codes.FailedPrecondition,
+			expectedNeeds: []string{"confirm_email"},
+		},
+		{
+			name:         "consumer with need exempted for the service",
+			method:       "/users.Users/Get",
+			needs:        []string{"confirm_email"},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "consumer with needs exempted for the service and the method",
+			method:       "/users.Users/Reset",
+			needs:        []string{"confirm_email", "password_reset"},
+			expectedCode: codes.OK,
+		},
+		{
+			name:          "consumer with need exempted for another method",
+			method:        "/users.Users/Get",
+			needs:         []string{"confirm_email", "password_reset"},
+			expectedCode:  codes.FailedPrecondition,
+			expectedNeeds: []string{"password_reset"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := lushauth.ContextWithConsumer(context.Background(), lushauth.Consumer{Needs: c.needs})
+			mw := lushauthmw.UnaryServerNeedsInterceptor(exemptions)
+			_, err := mw(ctx, nil, &grpc.UnaryServerInfo{FullMethod: c.method}, ok)
+			s := status.Convert(err)
+			test.Equals(t, c.expectedCode, s.Code())
+			var needs []string
+			for _, detail := range s.Details() {
+				if failure, ok := detail.(*errdetails.PreconditionFailure); ok {
+					for _, v := range failure.Violations {
+						needs = append(needs, v.Subject)
+					}
+				}
+			}
+			test.Equals(t, c.expectedNeeds, needs)
+		})
+	}
+}
+
+// contextServerStream is a server stream with nothing but a context.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerNeedsInterceptor(t *testing.T) {
+	cases := []struct {
+		name         string
+		method       string
+		needs        []string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "consumer without needs",
+			method:       "/orders.Orders/Watch",
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "consumer with outstanding needs",
+			method:       "/orders.Orders/Watch",
+			needs:        []string{"confirm_email"},
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:         "consumer with need exempted for the service",
+			method:       "/users.Users/Watch",
+			needs:        []string{"confirm_email"},
+			expectedCode: codes.OK,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := lushauth.ContextWithConsumer(context.Background(), lushauth.Consumer{Needs: c.needs})
+			var called bool
+			mw := lushauthmw.StreamServerNeedsInterceptor(exemptions)
+			err := mw(nil, contextServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: c.method}, func(srv interface{}, ss grpc.ServerStream) error {
+				called = true
+				return nil
+			})
+			test.Equals(t, c.expectedCode, status.Code(err))
+			test.Equals(t, c.expectedCode == codes.OK, called)
+		})
+	}
+}
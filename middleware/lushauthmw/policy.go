@@ -0,0 +1,166 @@
+package lushauthmw
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PublicMethod is a policy for methods that can be called without authenticating.
+var PublicMethod lushauth.Permitter = publicMethod{}
+
+type publicMethod struct{}
+
+// Permit permits any consumer.
+func (p publicMethod) Permit(c lushauth.Consumer) error {
+	return nil
+}
+
+// ContextPolicy adapts a ResourcePermitter to be used as the policy of a method or route,
+// such as a lushauth.ScopePolicy or lushauth.ActorPolicy.
+// The policy interceptors and policy middlewares evaluate it with the context of the request.
+// It can not be evaluated without that context, so it denies every consumer when nested in another Permitter.
+// Combine policies that need the context with lushauth.AllResourcePolicy or lushauth.AnyResourcePolicy instead.
+type ContextPolicy struct {
+	Policy lushauth.ResourcePermitter
+}
+
+// Permit denies every consumer, since the policy needs the context of the request.
+func (p ContextPolicy) Permit(c lushauth.Consumer) error {
+	return errContextMissing
+}
+
+func (p ContextPolicy) permitContext(ctx context.Context, c lushauth.Consumer) error {
+	if p.Policy == nil {
+		return errMethodDenied
+	}
+	return p.Policy.PermitResource(ctx, c, nil)
+}
+
+// errMethodDenied happens when a method is mapped to a nil policy.
+var errMethodDenied = errors.New("no policy permits calling the method")
+
+// errContextMissing happens when a ContextPolicy is evaluated without the context of the request.
+var errContextMissing = errors.New("policy needs the context of the request")
+
+// MethodPolicies maps full gRPC method names to the policies that callers need to be permitted by to call them.
+// Policies that need the context of the call, such as policies for services, scopes and actors,
+// can be used by wrapping them in a ContextPolicy.
+// A trailing * matches any method with the same prefix, so that a policy can be set for a whole service.
+// Methods mapped to a nil policy are denied to every caller.
+// e.g. "/users.Users/Delete" maps to lushauth.RolePolicy{"admin"}
+// and "/health.Health/*" maps to PublicMethod
+type MethodPolicies map[string]lushauth.Permitter
+
+// policy returns the policy for a method, where an exact match takes precedence over the longest wildcard match.
+func (m MethodPolicies) policy(method string) (lushauth.Permitter, bool) {
+	if p, ok := m[method]; ok {
+		return p, true
+	}
+	var (
+		policy  lushauth.Permitter
+		longest = -1
+	)
+	for pattern, p := range m {
+		if strings.HasSuffix(pattern, "*") && len(pattern) > longest && matchRoute(pattern, method) {
+			policy, longest = p, len(pattern)
+		}
+	}
+	return policy, longest >= 0
+}
+
+// authorize authenticates and authorizes a call to a method.
+// Public methods are not authenticated and methods without a policy only need to be authenticated.
+func (m MethodPolicies) authorize(ctx context.Context, method string, broker CopierRenewer, o *options) (context.Context, error) {
+	policy, ok := m.policy(method)
+	if _, public := policy.(publicMethod); public {
+		o.hooks.AuthEvent(ctx, authorizationEvent(ctx, method, nil))
+		return ctx, nil
+	}
+	claims, raw, err := interceptServerClaims(ctx, broker, o)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = contextWithAuth(ctx, claims, raw)
+	if ok {
+		if err := permitWithContext(ctx, policy, claims.Consumer); err != nil {
+			err := status.Error(codes.PermissionDenied, denial(policy, claims.Consumer, err))
+			o.hooks.AuthEvent(ctx, authorizationEvent(ctx, method, err))
+			if claims.Consumer.IsAnonymous() {
+				// The anonymous consumer has not provided any credentials, so it is asked to authenticate instead.
+				return ctx, ErrAuthTokenMissing
+			}
+			return ctx, err
+		}
+		o.hooks.AuthEvent(ctx, authorizationEvent(ctx, method, nil))
+	}
+	return ctx, nil
+}
+
+// permitWithContext permits a consumer with the context of the request, denying every consumer for a nil policy.
+func permitWithContext(ctx context.Context, policy lushauth.Permitter, c lushauth.Consumer) error {
+	switch p := policy.(type) {
+	case nil:
+		return errMethodDenied
+	case ContextPolicy:
+		return p.permitContext(ctx, c)
+	default:
+		return p.Permit(c)
+	}
+}
+
+// denial describes why a policy denied access.
+// Consumer policies are explained only once access is denied, since explaining evaluates every branch of the policy.
+func denial(policy lushauth.Permitter, c lushauth.Consumer, err error) string {
+	switch policy.(type) {
+	case nil, ContextPolicy:
+		return err.Error()
+	default:
+		return strings.TrimSuffix(lushauth.Explain(policy, c).String(), "\n")
+	}
+}
+
+// NewUnaryServerPolicyInterceptor creates a unary grpc server option with your key broker and method policies.
+func NewUnaryServerPolicyInterceptor(broker CopierRenewer, policies MethodPolicies, opts ...Option) grpc.ServerOption {
+	return grpc.UnaryInterceptor(UnaryServerPolicyInterceptor(broker, policies, opts...))
+}
+
+// NewStreamServerPolicyInterceptor creates a stream grpc server option with your key broker and method policies.
+func NewStreamServerPolicyInterceptor(broker CopierRenewer, policies MethodPolicies, opts ...Option) grpc.ServerOption {
+	return grpc.StreamInterceptor(StreamServerPolicyInterceptor(broker, policies, opts...))
+}
+
+// UnaryServerPolicyInterceptor is a gRPC server-side interceptor that checks that the JWT provided is valid
+// and that the consumer is permitted by the policy of the method for unary procedures.
+// It can be used in place of the UnaryServerInterceptor.
+func UnaryServerPolicyInterceptor(broker CopierRenewer, policies MethodPolicies, opts ...Option) func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	o := newOptions(opts...)
+	broker = coordinate(broker)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := policies.authorize(ctx, info.FullMethod, broker, o)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerPolicyInterceptor is a gRPC server-side interceptor that checks that the JWT provided is valid
+// and that the consumer is permitted by the policy of the method for streaming procedures.
+// It can be used in place of the StreamServerInterceptor.
+func StreamServerPolicyInterceptor(broker CopierRenewer, policies MethodPolicies, opts ...Option) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	o := newOptions(opts...)
+	broker = coordinate(broker)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := policies.authorize(ss.Context(), info.FullMethod, broker, o)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedServerStream{ss, ctx})
+	}
+}
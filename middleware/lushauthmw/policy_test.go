@@ -0,0 +1,257 @@
+package lushauthmw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/LUSHDigital/core/test"
+	"github.com/LUSHDigital/core/workers/keybroker/keybrokermock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func ExampleUnaryServerPolicyInterceptor() {
+	grpc.NewServer(
+		grpc.UnaryInterceptor(lushauthmw.UnaryServerPolicyInterceptor(broker, lushauthmw.MethodPolicies{
+			"/health.Health/*":    lushauthmw.PublicMethod,
+			"/users.Users/Delete": lushauth.RolePolicy{"admin"},
+			"/stock.Stock/Update": lushauthmw.ContextPolicy{Policy: lushauth.ScopePolicy{"stock.update"}},
+		})),
+	)
+}
+
+func TestUnaryServerPolicyInterceptor(t *testing.T) {
+	policies := lushauthmw.MethodPolicies{
+		"/health.Health/*":    lushauthmw.PublicMethod,
+		"/users.Users/*":      lushauth.RolePolicy{"staff"},
+		"/users.Users/Get":    lushauth.UserPolicy{validClaims.Consumer.UUID},
+		"/users.Users/Delete": lushauth.AnyPolicy{lushauth.RolePolicy{"admin"}, lushauth.GrantPolicy{"users.delete"}},
+		"/stock.Stock/*":      lushauthmw.ContextPolicy{Policy: lushauth.ServicePolicy{"stock-sync"}},
+		"/stock.Stock/Update": lushauthmw.ContextPolicy{Policy: lushauth.ScopePolicy{"stock.update"}},
+		"/stock.Stock/Delete": lushauthmw.ContextPolicy{Policy: lushauth.ScopePolicy{"stock.delete"}},
+		"/stock.Stock/Purge":  nil,
+		"/stock.Stock/Reset":  lushauthmw.ContextPolicy{},
+	}
+	serviceToken, err := lushauth.NewServiceIssuer(issuer, "Test", stockSync).Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name        string
+		method      string
+		token       string
+		expectedErr error
+	}{
+		{
+			name:   "public method without token",
+			method: "/health.Health/Check",
+		},
+		{
+			name:   "public method with invalid token",
+			method: "/health.Health/Check",
+			token:  expiredToken,
+		},
+		{
+			name:   "method without policy",
+			method: "/products.Products/List",
+			token:  validToken,
+		},
+		{
+			name:        "method without policy and without token",
+			method:      "/products.Products/List",
+			expectedErr: status.Error(codes.Unauthenticated, "metadata missing: auth-token"),
+		},
+		{
+			name:   "method permitted by exact policy",
+			method: "/users.Users/Get",
+			token:  validToken,
+		},
+		{
+			name:        "method denied by service policy",
+			method:      "/users.Users/List",
+			token:       validToken,
+			expectedErr: status.Error(codes.PermissionDenied, `deny role("staff"): need to have any of the "staff" roles`),
+		},
+		{
+			name:   "method denied by explained policy",
+			method: "/users.Users/Delete",
+			token:  validToken,
+			expectedErr: status.Error(codes.PermissionDenied, "deny any: none of the policies permitted access\n"+
+				"  deny role(\"admin\"): need to have any of the \"admin\" roles\n"+
+				"  deny grant(\"users.delete\"): need to have any of the \"users.delete\" grants"),
+		},
+		{
+			name:   "method permitted by service policy",
+			method: "/stock.Stock/List",
+			token:  serviceToken,
+		},
+		{
+			name:   "method permitted by scope policy",
+			method: "/stock.Stock/Update",
+			token:  serviceToken,
+		},
+		{
+			name:        "method denied by scope policy",
+			method:      "/stock.Stock/Delete",
+			token:       serviceToken,
+			expectedErr: status.Error(codes.PermissionDenied, `need to be a service with any of the "stock.delete" scopes`),
+		},
+		{
+			name:        "consumer denied by service policy",
+			method:      "/stock.Stock/List",
+			token:       validToken,
+			expectedErr: status.Error(codes.PermissionDenied, `need to be any of the "stock-sync" services`),
+		},
+		{
+			name:        "method denied by nil policy",
+			method:      "/stock.Stock/Purge",
+			token:       serviceToken,
+			expectedErr: status.Error(codes.PermissionDenied, "no policy permits calling the method"),
+		},
+		{
+			name:        "method denied by empty context policy",
+			method:      "/stock.Stock/Reset",
+			token:       serviceToken,
+			expectedErr: status.Error(codes.PermissionDenied, "no policy permits calling the method"),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			md := metadata.MD{}
+			if c.token != "" {
+				md.Set("auth-token", c.token)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			mw := lushauthmw.UnaryServerPolicyInterceptor(broker, policies)
+			_, err := mw(ctx, nil, &grpc.UnaryServerInfo{FullMethod: c.method}, ok)
+			test.Equals(t, c.expectedErr, err)
+		})
+	}
+}
+
+// explainCounter is a policy counting how often its decision was explained.
+type explainCounter struct {
+	lushauth.Permitter
+	explained int
+}
+
+func (p *explainCounter) Explain(c lushauth.Consumer) lushauth.Decision {
+	p.explained++
+	return lushauth.Explain(p.Permitter, c)
+}
+
+func TestUnaryServerPolicyInterceptor_ExplainsDenials(t *testing.T) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	permitted := &explainCounter{Permitter: lushauth.UserPolicy{validClaims.Consumer.UUID}}
+	denied := &explainCounter{Permitter: lushauth.RolePolicy{"admin"}}
+	mw := lushauthmw.UnaryServerPolicyInterceptor(broker, lushauthmw.MethodPolicies{
+		"/users.Users/Get":    permitted,
+		"/users.Users/Delete": denied,
+	})
+	md := metadata.MD{}
+	md.Set("auth-token", validToken)
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	_, err := mw(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/users.Users/Get"}, ok)
+	test.Equals(t, nil, err)
+	test.Equals(t, 0, permitted.explained)
+
+	_, err = mw(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/users.Users/Delete"}, ok)
+	test.Equals(t, codes.PermissionDenied, status.Code(err))
+	test.Equals(t, 1, denied.explained)
+}
+
+func TestUnaryServerPolicyInterceptor_AuthMode(t *testing.T) {
+	broker := keybrokermock.MockRSAPublicKey(public)
+	mw := lushauthmw.UnaryServerPolicyInterceptor(broker, lushauthmw.MethodPolicies{
+		"/users.Users/Delete": lushauth.RolePolicy{"admin"},
+	}, lushauthmw.WithAuthMode(lushauthmw.AuthOptional))
+	info := &grpc.UnaryServerInfo{FullMethod: "/users.Users/Delete"}
+
+	t.Run("anonymous consumer denied by policy", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{})
+		_, err := mw(ctx, nil, info, ok)
+		test.Equals(t, codes.Unauthenticated, status.Code(err))
+	})
+	t.Run("consumer denied by policy", func(t *testing.T) {
+		md := metadata.MD{}
+		md.Set("auth-token", validToken)
+		_, err := mw(metadata.NewIncomingContext(context.Background(), md), nil, info, ok)
+		test.Equals(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+// impersonatedAdminToken issues a token for an admin with a support agent acting on their behalf.
+func impersonatedAdminToken(t *testing.T) string {
+	admin := validClaims.Consumer
+	admin.Roles = []string{"admin"}
+	support := lushauth.Consumer{UUID: "9c1a5b1e-8c3f-4c0b-9a5e-6f1f0d3b2a11", Roles: []string{"support"}}
+	claims, err := lushauth.NewClaimsForImpersonation("Test", support, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := issuer.Issue(&claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestUnaryServerPolicyInterceptor_ContextPolicy(t *testing.T) {
+	impersonated := impersonatedAdminToken(t)
+	noImpersonation := lushauthmw.ContextPolicy{Policy: lushauth.NoImpersonationPolicy{}}
+	policies := lushauthmw.MethodPolicies{
+		"/users.Users/Get":    noImpersonation,
+		"/users.Users/Delete": lushauth.AllPolicy{lushauth.RolePolicy{"admin"}, noImpersonation},
+		"/users.Users/Update": lushauthmw.ContextPolicy{Policy: lushauth.AllResourcePolicy{
+			lushauth.PermitterPolicy{Policy: lushauth.RolePolicy{"admin"}},
+			lushauth.NoImpersonationPolicy{},
+		}},
+	}
+	cases := []struct {
+		name         string
+		method       string
+		token        string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "context policy permits consumer",
+			method:       "/users.Users/Get",
+			token:        validToken,
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "context policy denies impersonation",
+			method:       "/users.Users/Get",
+			token:        impersonated,
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "nested context policy denies impersonation",
+			method:       "/users.Users/Delete",
+			token:        impersonated,
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "combined context policies deny impersonation",
+			method:       "/users.Users/Update",
+			token:        impersonated,
+			expectedCode: codes.PermissionDenied,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			broker := keybrokermock.MockRSAPublicKey(public)
+			md := metadata.MD{}
+			md.Set("auth-token", c.token)
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			_, err := lushauthmw.UnaryServerPolicyInterceptor(broker, policies)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: c.method}, ok)
+			test.Equals(t, c.expectedCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package lushauthmw
+
+import (
+	"crypto/rsa"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core/auth"
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// DefaultMinRenewalBackoff is the least time to wait between renewals of the public key.
+	DefaultMinRenewalBackoff = 5 * time.Second
+	// DefaultMaxRenewalBackoff is the most time to wait between renewals of the public key.
+	DefaultMaxRenewalBackoff = 5 * time.Minute
+)
+
+// RenewalCoordinator protects a key broker from being asked to renew its public key too often.
+// Concurrent renewals are deduplicated into a single one and renewals are not passed on within the backoff of the last one.
+// The backoff doubles every time a renewal did not yield a new public key and resets once one does.
+// Every auth middleware wraps the broker it is given in its own coordinator when it is created. Give a single
+// coordinator to every middleware in place of the broker to coordinate the renewals of all of them together.
+type RenewalCoordinator struct {
+	// Broker is the key broker whose public key is renewed.
+	Broker CopierRenewer
+	// MinBackoff is the least time to wait between renewals.
+	MinBackoff time.Duration
+	// MaxBackoff is the most time to wait between renewals.
+	MaxBackoff time.Duration
+
+	mu       sync.Mutex
+	renewing bool
+	renewed  bool
+	key      rsa.PublicKey
+	backoff  time.Duration
+	next     time.Time
+}
+
+// NewRenewalCoordinator creates a renewal coordinator for a key broker with the default backoff.
+func NewRenewalCoordinator(broker CopierRenewer) *RenewalCoordinator {
+	return &RenewalCoordinator{
+		Broker:     broker,
+		MinBackoff: DefaultMinRenewalBackoff,
+		MaxBackoff: DefaultMaxRenewalBackoff,
+	}
+}
+
+// Copy returns a copy of the public key of the broker.
+func (c *RenewalCoordinator) Copy() rsa.PublicKey {
+	return c.Broker.Copy()
+}
+
+// Renew asks the broker to renew its public key, unless a renewal is already in progress or within its backoff.
+func (c *RenewalCoordinator) Renew() {
+	c.mu.Lock()
+	now := lushauth.TimeFunc()
+	if c.renewing || now.Before(c.next) {
+		c.mu.Unlock()
+		return
+	}
+	c.renewing = true
+	key := c.Broker.Copy()
+	switch {
+	case !c.renewed || !equalPublicKeys(c.key, key):
+		c.backoff = c.MinBackoff
+	case c.backoff*2 > c.MaxBackoff:
+		c.backoff = c.MaxBackoff
+	default:
+		c.backoff *= 2
+	}
+	c.renewed, c.key = true, key
+	c.mu.Unlock()
+
+	c.Broker.Renew()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.renewing = false
+	c.next = lushauth.TimeFunc().Add(c.backoff)
+}
+
+// coordinate wraps a key broker in a renewal coordinator, unless it already is one.
+func coordinate(broker CopierRenewer) CopierRenewer {
+	if c, ok := broker.(*RenewalCoordinator); ok {
+		return c
+	}
+	return NewRenewalCoordinator(broker)
+}
+
+// withoutRenewal is a key broker that is never asked to renew its public key.
+type withoutRenewal struct {
+	CopierRenewer
+}
+
+// Renew does nothing.
+func (b withoutRenewal) Renew() {}
+
+// parseClaims parses and validates a token with the key function against the public key of the broker.
+// The public key is renewed when the token is signed by an unknown key, but not for any other kind of invalid token.
+func parseClaims(raw string, broker CopierRenewer, fn auth.PublicKeyFunc, claims *lushauth.Claims) error {
+	pk := broker.Copy()
+	parser := auth.NewParser(&pk, fn)
+	err := parser.Parse(raw, claims)
+	if unknownKey(err) {
+		broker.Renew()
+	}
+	return err
+}
+
+// unknownKey checks if an error happened because an RSA signature could not be verified with the current public key.
+func unknownKey(err error) bool {
+	var e *jwt.ValidationError
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.Errors&jwt.ValidationErrorSignatureInvalid != 0 && e.Inner == rsa.ErrVerification
+}
+
+func equalPublicKeys(a, b rsa.PublicKey) bool {
+	if a.N == nil || b.N == nil {
+		return a.N == b.N && a.E == b.E
+	}
+	return a.N.Cmp(b.N) == 0 && a.E == b.E
+}
@@ -0,0 +1,230 @@
+package lushauthmw_test
+
+import (
+	"context"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LUSHDigital/core-lush/lushauth"
+	"github.com/LUSHDigital/core-lush/middleware/lushauthmw"
+	"github.com/LUSHDigital/core/test"
+	"github.com/LUSHDigital/core/workers/keybroker/keybrokermock"
+	jwt "github.com/dgrijalva/jwt-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// renewalCounter is a key broker counting the renewals of its public key, where each renewal waits for the release channel.
+type renewalCounter struct {
+	lushauthmw.CopierRenewer
+	release chan struct{}
+
+	mu       sync.Mutex
+	renewals int
+}
+
+func (b *renewalCounter) Renew() {
+	if b.release != nil {
+		<-b.release
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.renewals++
+}
+
+func (b *renewalCounter) Renewals() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.renewals
+}
+
+func ExampleNewRenewalCoordinator() {
+	coordinator := lushauthmw.NewRenewalCoordinator(broker)
+	coordinator.MaxBackoff = time.Minute
+	lushauthmw.JWTMiddleware(coordinator)
+	lushauthmw.NewUnaryServerInterceptor(coordinator)
+}
+
+func TestRenewalCoordinator_Concurrent(t *testing.T) {
+	release := make(chan struct{})
+	counter := &renewalCounter{CopierRenewer: keybrokermock.MockRSAPublicKey(public), release: release}
+	coordinator := lushauthmw.NewRenewalCoordinator(counter)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			coordinator.Renew()
+		}()
+	}
+	// Only the renewal that was passed on to the broker waits to be released.
+	release <- struct{}{}
+	wg.Wait()
+	test.Equals(t, 1, counter.Renewals())
+}
+
+func TestRenewalCoordinator_Backoff(t *testing.T) {
+	defer func(fn func() time.Time) { lushauth.TimeFunc = fn }(lushauth.TimeFunc)
+	clock := now
+	lushauth.TimeFunc = func() time.Time { return clock }
+
+	key := keybrokermock.MockRSAPublicKey(public)
+	counter := &renewalCounter{CopierRenewer: key}
+	coordinator := &lushauthmw.RenewalCoordinator{
+		Broker:     counter,
+		MinBackoff: time.Second,
+		MaxBackoff: 3 * time.Second,
+	}
+	steps := []struct {
+		name     string
+		after    time.Duration
+		rotate   *rsa.PublicKey
+		renewals int
+	}{
+		{name: "first renewal", renewals: 1},
+		{name: "within min backoff", after: 500 * time.Millisecond, renewals: 1},
+		{name: "after min backoff", after: 500 * time.Millisecond, renewals: 2},
+		{name: "within doubled backoff", after: time.Second, renewals: 2},
+		{name: "after doubled backoff", after: time.Second, renewals: 3},
+		{name: "within max backoff", after: 2 * time.Second, renewals: 3},
+		{name: "after max backoff", after: time.Second, renewals: 4},
+		{name: "resets once the key was renewed", after: 3 * time.Second, rotate: incorrectPublic, renewals: 5},
+		{name: "after min backoff again", after: time.Second, renewals: 6},
+	}
+	for _, s := range steps {
+		clock = clock.Add(s.after)
+		if s.rotate != nil {
+			counter.CopierRenewer = keybrokermock.MockRSAPublicKey(s.rotate)
+		}
+		coordinator.Renew()
+		test.Equals(t, s.renewals, counter.Renewals())
+	}
+}
+
+func TestJWTHandler_Renewal(t *testing.T) {
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &validClaims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		tokens   []string
+		renewals int
+	}{
+		{
+			name:     "token is good",
+			tokens:   []string{validToken},
+			renewals: 0,
+		},
+		{
+			name:     "token has expired",
+			tokens:   []string{expiredToken},
+			renewals: 0,
+		},
+		{
+			name:     "token is malformed",
+			tokens:   []string{"i am invalid"},
+			renewals: 0,
+		},
+		{
+			name:     "token is signed with HMAC",
+			tokens:   []string{hs256, hs256, hs256},
+			renewals: 0,
+		},
+		{
+			name:     "token is signed with an unknown key",
+			tokens:   []string{invalidToken},
+			renewals: 1,
+		},
+		{
+			name:     "tokens are signed with an unknown key",
+			tokens:   []string{invalidToken, invalidToken, invalidToken},
+			renewals: 1,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			counter := &renewalCounter{CopierRenewer: keybrokermock.MockRSAPublicKey(public)}
+			handler := lushauthmw.JWTHandler(counter, func(w http.ResponseWriter, r *http.Request) {})
+			for _, token := range c.tokens {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+			test.Equals(t, c.renewals, counter.Renewals())
+		})
+	}
+}
+
+func TestUnaryServerInterceptor_Renewal(t *testing.T) {
+	counter := &renewalCounter{CopierRenewer: keybrokermock.MockRSAPublicKey(public)}
+	mw := lushauthmw.UnaryServerInterceptor(counter)
+	for _, token := range []string{invalidToken, invalidToken, expiredToken} {
+		md := metadata.MD{}
+		md.Set("auth-token", token)
+		mw(metadata.NewIncomingContext(context.Background(), md), nil, nil, ok)
+	}
+	test.Equals(t, 1, counter.Renewals())
+}
+
+func TestInterceptServerJWT_Renewal(t *testing.T) {
+	md := metadata.MD{}
+	md.Set("auth-token", invalidToken)
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	t.Run("with a coordinator", func(t *testing.T) {
+		counter := &renewalCounter{CopierRenewer: keybrokermock.MockRSAPublicKey(public)}
+		coordinator := lushauthmw.NewRenewalCoordinator(counter)
+		for i := 0; i < 20; i++ {
+			lushauthmw.InterceptServerJWT(ctx, coordinator)
+		}
+		test.Equals(t, 1, counter.Renewals())
+	})
+	t.Run("without a coordinator", func(t *testing.T) {
+		counter := &renewalCounter{CopierRenewer: keybrokermock.MockRSAPublicKey(public)}
+		for i := 0; i < 20; i++ {
+			lushauthmw.InterceptServerJWT(ctx, counter)
+		}
+		test.Equals(t, 0, counter.Renewals())
+	})
+}
+
+func TestRenewal_SharedBetweenTransports(t *testing.T) {
+	counter := &renewalCounter{CopierRenewer: keybrokermock.MockRSAPublicKey(public)}
+	coordinator := lushauthmw.NewRenewalCoordinator(counter)
+	next := func(w http.ResponseWriter, r *http.Request) {}
+	handlers := []http.Handler{
+		lushauthmw.JWTHandler(coordinator, next),
+		lushauthmw.JWTHandler(coordinator, next),
+	}
+	for _, handler := range handlers {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+invalidToken)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	md := metadata.MD{}
+	md.Set("auth-token", invalidToken)
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	lushauthmw.UnaryServerInterceptor(coordinator)(ctx, nil, nil, ok)
+	lushauthmw.UnaryServerPolicyInterceptor(coordinator, nil)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/users.Users/Get"}, ok)
+	test.Equals(t, 1, counter.Renewals())
+}
+
+func TestJWTHandler_RenewalUncomparableBroker(t *testing.T) {
+	counter := &renewalCounter{CopierRenewer: keybrokermock.MockRSAPublicKey(public)}
+	broker := struct {
+		lushauthmw.CopierRenewer
+		labels map[string]string
+	}{CopierRenewer: counter}
+	handler := lushauthmw.JWTHandler(broker, func(w http.ResponseWriter, r *http.Request) {})
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+invalidToken)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	test.Equals(t, 1, counter.Renewals())
+}